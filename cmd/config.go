@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+)
+
+const configUsage = `usage: config <command>
+
+commands:
+  print         print the effective configuration and where each value came from`
+
+// runConfig executes the config subcommand given its arguments.
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+	return cfg.Print(os.Stdout)
+}
@@ -1,40 +1,169 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
+
 	_ "github.com/Mukam21/server_Golang/docs"
 	"github.com/Mukam21/server_Golang/pkg/config"
 	"github.com/Mukam21/server_Golang/pkg/database"
 	"github.com/Mukam21/server_Golang/pkg/handler"
+	"github.com/Mukam21/server_Golang/pkg/logging"
+	"github.com/Mukam21/server_Golang/pkg/metrics"
 	"github.com/Mukam21/server_Golang/pkg/service"
+	"github.com/Mukam21/server_Golang/pkg/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// @title Person Service API
+// @version 1.0
+// @description API for managing persons with enrichment
+// @host localhost:8080
+// @BasePath /api/v1
+// @schemes http
 func main() {
-	log := logrus.New()
-	cfg, err := config.Load()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal("Failed to load config: ", err)
+		// config print is most useful on exactly the configurations that
+		// fail validation, so it still prints what was merged.
+		var invalid *config.InvalidError
+		if errors.As(err, &invalid) && len(args) > 0 && args[0] == "config" {
+			if err := runConfig(invalid.Config, args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		// The errors span several lines, which read better outside a log
+		// entry.
+		fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(1)
 	}
+	log := logging.New(cfg)
 
-	db, err := database.NewPostgresDB(cfg)
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "", "migrate":
+	case "config":
+		if err := runConfig(cfg, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("Unknown command %q; expected migrate or config", command)
+	}
+
+	db, err := database.NewPostgresDB(cfg, log)
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
 
-	repo := database.NewRepository(db)
-	srv := service.NewService(repo, log, cfg)
-	h := handler.NewHandler(srv, log)
+	if command == "migrate" {
+		if err := runMigrate(context.Background(), db, cfg, log, args[1:]); err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+	if cfg.AutoMigrate {
+		if err := database.RunMigrations(context.Background(), db, cfg.MigrateLockTimeout, log); err != nil {
+			log.Fatal("Failed to migrate database: ", err)
+		}
+	}
 
-	r := gin.Default()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatal("Failed to set up tracing: ", err)
+	}
+	metrics.RegisterDB(db)
+
+	store := config.NewStore(cfg, os.Args[1:])
+	store.OnReload(func(cfg *config.Config) { logging.Apply(log, cfg) })
+
+	baseRepo := database.NewRepository(db, cfg, log)
+	collisions, err := baseRepo.BackfillNaturalKeys(context.Background())
+	if err != nil {
+		log.Error("Failed to backfill natural keys: ", err)
+	} else if len(collisions) > 0 {
+		log.Warnf("%d persons share their name with an older person and are not covered by the uniqueness policy until merged: %v",
+			len(collisions), collisions)
+	}
+	repo := database.Instrument(baseRepo)
+	srv := service.NewService(repo, log, store)
+	h := handler.NewHandler(srv, log, store)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	srv.Start(workerCtx)
+
+	// Requests are logged by the handler's access log; panics go to the
+	// same logger.
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(log.WriterLevel(logrus.ErrorLevel)))
 	h.InitRoutes(r)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	log.Info("Starting server on port: ", cfg.ServerPort)
-	if err := r.Run(":" + cfg.ServerPort); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		ErrorLog:          stdlog.New(log.WriterLevel(logrus.WarnLevel), "", 0),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go watchConfig(ctx, store, log)
+
+	go func() {
+		log.Info("Starting server on port: ", cfg.ServerPort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server: ", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	// Fail readiness first and give load balancers time to notice before
+	// the listener goes away.
+	cfg = store.Current()
+	log.Infof("Shutting down, draining for %s", cfg.ShutdownDrain)
+	h.StartDraining()
+	time.Sleep(cfg.ShutdownDrain)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Warn("Failed to finish in-flight requests: ", err)
+	}
+	stopWorkers()
+	if err := srv.Wait(shutdownCtx); err != nil {
+		log.Warn("Background work did not finish in time: ", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Warn("Failed to close database: ", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Warn("Failed to flush traces: ", err)
 	}
+	log.Info("Server stopped")
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/database"
+	"github.com/sirupsen/logrus"
+)
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [N]      roll back the last N migrations (default 1)
+  goto V        migrate up or down to version V
+  status        print the current version and pending migrations
+  force V       mark version V as applied and clear the dirty flag`
+
+// runMigrate executes the migrate subcommand given its arguments.
+func runMigrate(ctx context.Context, db *sql.DB, cfg *config.Config, log *logrus.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := database.NewMigrator(ctx, db, cfg.MigrateLockTimeout, log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations: %q", args[1])
+			}
+		}
+		err = m.Down(steps)
+	case "goto":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, perr := strconv.ParseUint(args[1], 10, 64)
+		if perr != nil {
+			return fmt.Errorf("invalid version: %q", args[1])
+		}
+		err = m.Goto(uint(version))
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, perr := strconv.Atoi(args[1])
+		if perr != nil {
+			return fmt.Errorf("invalid version: %q", args[1])
+		}
+		err = m.Force(version)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version: %d\n", status.Version)
+	if status.Dirty {
+		fmt.Println("dirty: true, fix the failed migration and run force")
+	}
+	if len(status.Pending) == 0 {
+		fmt.Println("pending: none")
+	} else {
+		fmt.Printf("pending: %v\n", status.Pending)
+	}
+	return nil
+}
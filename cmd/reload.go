@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// watchConfig reloads the configuration on SIGHUP and, every
+// CONFIG_RELOAD_INTERVAL, when the config file's contents have changed,
+// until ctx is done. Comparing contents rather than modification times
+// also catches files swapped through symlinks, as Kubernetes does with
+// mounted ConfigMaps.
+func watchConfig(ctx context.Context, store *config.Store, log *logrus.Logger) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	cfg := store.Current()
+	var changes <-chan time.Time
+	var last []byte
+	if cfg.File() != "" && cfg.ConfigReloadInterval > 0 {
+		last, _ = os.ReadFile(cfg.File())
+		ticker := time.NewTicker(cfg.ConfigReloadInterval)
+		defer ticker.Stop()
+		changes = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Info("Reloading configuration on SIGHUP")
+		case <-changes:
+			// A file being replaced may briefly be missing; the next tick
+			// sees it again.
+			data, err := os.ReadFile(cfg.File())
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			log.Infof("Config file %s changed, reloading configuration", cfg.File())
+		}
+		reloadConfig(store, log)
+	}
+}
+
+func reloadConfig(store *config.Store, log *logrus.Logger) {
+	ignored, err := store.Reload()
+	if err != nil {
+		log.Errorf("Rejected reloaded configuration, keeping the current one: %v", err)
+		return
+	}
+	if len(ignored) > 0 {
+		log.Warnf("Restart to apply changes to %s", strings.Join(ignored, ", "))
+	}
+	log.Info("Configuration reloaded")
+}
@@ -0,0 +1,10 @@
+DROP INDEX idx_persons_updated_at;
+DROP INDEX idx_persons_created_at;
+
+DROP TRIGGER persons_set_updated_at ON persons;
+DROP FUNCTION set_updated_at();
+
+ALTER TABLE persons
+    DROP COLUMN updated_at,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
@@ -0,0 +1,22 @@
+ALTER TABLE persons
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET NOT NULL,
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE persons SET updated_at = created_at;
+
+CREATE FUNCTION set_updated_at() RETURNS TRIGGER AS
+$$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER persons_set_updated_at
+    BEFORE UPDATE ON persons
+    FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX idx_persons_created_at ON persons (created_at);
+CREATE INDEX idx_persons_updated_at ON persons (updated_at);
@@ -0,0 +1,4 @@
+DROP INDEX idx_persons_deleted_at;
+
+ALTER TABLE persons
+    DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE persons
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_persons_deleted_at ON persons (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,12 @@
+CREATE TABLE person_audit
+(
+    id BIGSERIAL PRIMARY KEY,
+    person_id INTEGER NOT NULL,
+    operation VARCHAR(20) NOT NULL,
+    actor VARCHAR(255),
+    request_id VARCHAR(255),
+    version BIGINT NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_person_audit_person_id ON person_audit (person_id, id);
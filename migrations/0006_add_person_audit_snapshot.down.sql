@@ -0,0 +1,5 @@
+DROP INDEX idx_person_audit_person_changed_at;
+DROP INDEX idx_person_audit_person_version;
+
+ALTER TABLE person_audit
+    DROP COLUMN snapshot;
@@ -0,0 +1,10 @@
+ALTER TABLE person_audit
+    ADD COLUMN snapshot JSONB;
+
+UPDATE person_audit a
+SET snapshot = to_jsonb(p)
+FROM persons p
+WHERE a.person_id = p.id AND a.version = p.version;
+
+CREATE INDEX idx_person_audit_person_version ON person_audit (person_id, version);
+CREATE INDEX idx_person_audit_person_changed_at ON person_audit (person_id, changed_at);
@@ -0,0 +1,5 @@
+DROP INDEX idx_persons_natural_key_expr;
+DROP INDEX idx_persons_natural_key;
+
+ALTER TABLE persons
+    DROP COLUMN natural_key;
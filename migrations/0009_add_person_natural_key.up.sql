@@ -0,0 +1,9 @@
+-- natural_key is only filled in under the strict uniqueness policy; NULLs
+-- never conflict, so the index enforces nothing otherwise.
+ALTER TABLE persons
+    ADD COLUMN natural_key TEXT;
+
+CREATE UNIQUE INDEX idx_persons_natural_key ON persons (natural_key) WHERE deleted_at IS NULL;
+CREATE INDEX idx_persons_natural_key_expr
+    ON persons ((lower(trim(name)) || '|' || lower(trim(surname)) || '|' || lower(trim(coalesce(patronymic, '')))))
+    WHERE deleted_at IS NULL;
@@ -0,0 +1,15 @@
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS
+$$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE INDEX idx_persons_natural_key_expr
+    ON persons ((lower(trim(name)) || '|' || lower(trim(surname)) || '|' || lower(trim(coalesce(patronymic, '')))))
+    WHERE deleted_at IS NULL;
+DROP INDEX idx_persons_name_key;
+
+ALTER TABLE persons
+    DROP COLUMN name_key;
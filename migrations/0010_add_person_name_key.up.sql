@@ -0,0 +1,20 @@
+-- name_key holds model.NaturalKey for every person whatever the uniqueness
+-- policy. The service computes it, so lookups by natural key use exactly the
+-- Go normalization rather than an SQL approximation of it; rows written
+-- before this migration are filled in by Repository.BackfillNaturalKeys.
+ALTER TABLE persons
+    ADD COLUMN name_key TEXT;
+
+CREATE INDEX idx_persons_name_key ON persons (name_key) WHERE deleted_at IS NULL;
+DROP INDEX idx_persons_natural_key_expr;
+
+-- Filling in the derived key columns is not a change to the person.
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF to_jsonb(NEW) - 'name_key' - 'natural_key' IS DISTINCT FROM to_jsonb(OLD) - 'name_key' - 'natural_key' THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1 @@
+DELETE FROM person_audit WHERE operation = 'baseline';
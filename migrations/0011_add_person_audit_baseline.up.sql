@@ -0,0 +1,9 @@
+-- Persons that predate the audit log have no snapshot to read as of a point
+-- in time or to revert to. Record their current state as a baseline entry,
+-- dated by their last change; earlier states are unknown.
+INSERT INTO person_audit (person_id, operation, version, snapshot, changed_at)
+SELECT p.id, 'baseline', p.version, to_jsonb(p) - 'name_key' - 'natural_key', p.updated_at
+FROM persons p
+WHERE NOT EXISTS (
+    SELECT 1 FROM person_audit a WHERE a.person_id = p.id AND a.snapshot IS NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Each claim gets a random token so that a holder whose claim was taken over
+-- after IDEMPOTENCY_LOCK_TIMEOUT cannot overwrite or release the new claim.
+ALTER TABLE idempotency_keys ADD COLUMN token CHAR(32);
@@ -0,0 +1,22 @@
+CREATE TABLE import_jobs
+(
+    id CHAR(32) PRIMARY KEY,
+    status VARCHAR(20) NOT NULL,
+    dry_run BOOLEAN NOT NULL,
+    total_rows INTEGER NOT NULL,
+    imported INTEGER NOT NULL DEFAULT 0,
+    rejected INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ
+);
+CREATE INDEX idx_import_jobs_updated_at ON import_jobs (updated_at);
+
+CREATE TABLE import_job_errors
+(
+    job_id CHAR(32) NOT NULL REFERENCES import_jobs (id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    PRIMARY KEY (job_id, row_number)
+);
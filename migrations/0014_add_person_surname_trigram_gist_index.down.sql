@@ -0,0 +1,2 @@
+CREATE INDEX idx_persons_surname_trgm ON persons USING gin (lower(surname) gin_trgm_ops) WHERE deleted_at IS NULL;
+DROP INDEX idx_persons_surname_trgm_gist;
@@ -0,0 +1,6 @@
+-- GiST supports ordering by trigram distance (<->), so duplicate candidates
+-- can be found as each person's nearest surnames instead of by a self-join.
+CREATE INDEX idx_persons_surname_trgm_gist ON persons USING gist (lower(surname) gist_trgm_ops) WHERE deleted_at IS NULL;
+-- The GIN index served only the old self-join. The surname filter of the
+-- list (surname ILIKE) is not on lower(surname) and cannot use either index.
+DROP INDEX idx_persons_surname_trgm;
@@ -0,0 +1,37 @@
+// Package migrations holds the database schema migrations, embedded into the
+// binary so they run the same regardless of the working directory.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// FS contains the migrations as golang-migrate expects them:
+// <version>_<title>.up.sql and <version>_<title>.down.sql.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Latest returns the version of the newest embedded migration, which is the
+// version a fully migrated database is at.
+func Latest() (uint, error) {
+	entries, err := fs.ReadDir(FS, ".")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		m, err := source.Parse(entry.Name())
+		if err != nil {
+			continue
+		}
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return latest, nil
+}
@@ -1,38 +1,363 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
-
-	"github.com/joho/godotenv"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Config is the service configuration. Each field is set from, in
+// increasing order of precedence, its default, the config file, the
+// environment and command-line flags. The config tag names the environment
+// variable; the file uses it in lower case and the flag in lower case with
+// dashes, so DB_HOST is db_host in the file and --db-host on the command
+// line. Settings tagged static are only read at startup; see Store.Reload.
 type Config struct {
-	ServerPort        string
-	DBHost            string
-	DBPort            string
-	DBUser            string
-	DBPassword        string
-	DBName            string
-	APIAgifyURL       string
-	APIGenderizeURL   string
-	APINationalizeURL string
-}
-
-func Load() (*Config, error) {
-	err := godotenv.Load()
+	ServerPort              string        `config:"SERVER_PORT" default:"8080" static:"true"`
+	DBHost                  string        `config:"DB_HOST" static:"true"`
+	DBPort                  string        `config:"DB_PORT" default:"5432" static:"true"`
+	DBUser                  string        `config:"DB_USER" static:"true"`
+	DBPassword              string        `config:"DB_PASSWORD" secret:"true" static:"true"`
+	DBName                  string        `config:"DB_NAME" static:"true"`
+	DatabaseURL             string        `config:"DATABASE_URL" secret:"true" static:"true"`
+	DBSSLMode               string        `config:"DB_SSLMODE" static:"true"`
+	DBSSLRootCert           string        `config:"DB_SSLROOTCERT" static:"true"`
+	DBSSLCert               string        `config:"DB_SSLCERT" static:"true"`
+	DBSSLKey                string        `config:"DB_SSLKEY" static:"true"`
+	DBMaxOpenConns          int           `config:"DB_MAX_OPEN_CONNS" default:"25" static:"true"`
+	DBMaxIdleConns          int           `config:"DB_MAX_IDLE_CONNS" default:"10" static:"true"`
+	DBConnMaxLifetime       time.Duration `config:"DB_CONN_MAX_LIFETIME" default:"30m" static:"true"`
+	DBConnMaxIdleTime       time.Duration `config:"DB_CONN_MAX_IDLE_TIME" default:"5m" static:"true"`
+	DBConnectTimeout        time.Duration `config:"DB_CONNECT_TIMEOUT" default:"5s" static:"true"`
+	DBConnectRetries        int           `config:"DB_CONNECT_RETRIES" default:"5" static:"true"`
+	DBConnectBackoff        time.Duration `config:"DB_CONNECT_BACKOFF" default:"1s" static:"true"`
+	DBStatementTimeout      time.Duration `config:"DB_STATEMENT_TIMEOUT" default:"0" static:"true"`
+	APIAgifyURL             string        `config:"API_AGIFY_URL" default:"https://api.agify.io"`
+	APIGenderizeURL         string        `config:"API_GENDERIZE_URL" default:"https://api.genderize.io"`
+	APINationalizeURL       string        `config:"API_NATIONALIZE_URL" default:"https://api.nationalize.io"`
+	APIAgifyKey             string        `config:"API_AGIFY_KEY" secret:"true"`
+	APIGenderizeKey         string        `config:"API_GENDERIZE_KEY" secret:"true"`
+	APINationalizeKey       string        `config:"API_NATIONALIZE_KEY" secret:"true"`
+	AdminToken              string        `config:"ADMIN_TOKEN" secret:"true"`
+	SoftDeleteRetentionDays int           `config:"SOFT_DELETE_RETENTION_DAYS" default:"0" static:"true"`
+	PurgeInterval           time.Duration `config:"PURGE_INTERVAL" default:"1h" static:"true"`
+	EnrichTimeout           time.Duration `config:"ENRICH_TIMEOUT" default:"5s"`
+	BatchMaxItems           int           `config:"BATCH_MAX_ITEMS" default:"1000"`
+	ImportMaxBytes          int           `config:"IMPORT_MAX_BYTES" default:"52428800"`
+	ImportAsyncRows         int           `config:"IMPORT_ASYNC_ROWS" default:"1000"`
+	ImportJobTTL            time.Duration `config:"IMPORT_JOB_TTL" default:"24h"`
+	IdempotencyKeyTTL       time.Duration `config:"IDEMPOTENCY_KEY_TTL" default:"24h"`
+	IdempotencyLockTimeout  time.Duration `config:"IDEMPOTENCY_LOCK_TIMEOUT" default:"5m"`
+	UniquenessPolicy        string        `config:"UNIQUENESS_POLICY" default:"none" static:"true"`
+	DBTxIsolation           string        `config:"DB_TX_ISOLATION" default:"read_committed" static:"true"`
+	DBTxMaxRetries          int           `config:"DB_TX_MAX_RETRIES" default:"3" static:"true"`
+	AutoMigrate             bool          `config:"AUTO_MIGRATE" default:"false" static:"true"`
+	MigrateLockTimeout      time.Duration `config:"MIGRATE_LOCK_TIMEOUT" default:"5m" static:"true"`
+	HTTPReadHeaderTimeout   time.Duration `config:"HTTP_READ_HEADER_TIMEOUT" default:"10s" static:"true"`
+	HTTPReadTimeout         time.Duration `config:"HTTP_READ_TIMEOUT" default:"1m" static:"true"`
+	HTTPWriteTimeout        time.Duration `config:"HTTP_WRITE_TIMEOUT" default:"1m" static:"true"`
+	HTTPIdleTimeout         time.Duration `config:"HTTP_IDLE_TIMEOUT" default:"2m" static:"true"`
+	ShutdownDrain           time.Duration `config:"SHUTDOWN_DRAIN" default:"5s"`
+	ShutdownTimeout         time.Duration `config:"SHUTDOWN_TIMEOUT" default:"30s"`
+	EnrichBreakerThreshold  int           `config:"ENRICH_BREAKER_THRESHOLD" default:"5"`
+	EnrichBreakerCooldown   time.Duration `config:"ENRICH_BREAKER_COOLDOWN" default:"30s"`
+	EnrichCacheTTL          time.Duration `config:"ENRICH_CACHE_TTL" default:"0"`
+	EnrichCacheSize         int           `config:"ENRICH_CACHE_SIZE" default:"10000"`
+	HealthCheckTimeout      time.Duration `config:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	HealthProbeInterval     time.Duration `config:"HEALTH_PROBE_INTERVAL" default:"30s"`
+	// HealthCritical lists the enrichment providers whose outage fails
+	// readiness; the database always does.
+	HealthCritical      []string `config:"HEALTH_CRITICAL_DEPENDENCIES"`
+	TracingExporter     string   `config:"TRACING_EXPORTER" default:"none" static:"true"`
+	TracingOTLPEndpoint string   `config:"TRACING_OTLP_ENDPOINT" default:"http://localhost:4318/v1/traces" static:"true"`
+	TracingSampleRatio  float64  `config:"TRACING_SAMPLE_RATIO" default:"1" static:"true"`
+	LogLevel            string   `config:"LOG_LEVEL" default:"info"`
+	LogFormat           string   `config:"LOG_FORMAT" default:"json" static:"true"`
+	// LogRedactPersonalData masks names and other personal data in logs.
+	LogRedactPersonalData bool          `config:"LOG_REDACT_PERSONAL_DATA" default:"true"`
+	ConfigReloadInterval  time.Duration `config:"CONFIG_RELOAD_INTERVAL" default:"5s" static:"true"`
+
+	// sources records where each setting came from, for Print.
+	sources map[string]string
+	// file is the config file read, if any.
+	file string
+	// invalid holds the raw values of settings that failed to parse, for
+	// Print.
+	invalid map[string]string
+}
+
+// Log formats.
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// TLS modes of the database connection, as lib/pq names them.
+const (
+	DBSSLDisable    = "disable"
+	DBSSLRequire    = "require"
+	DBSSLVerifyCA   = "verify-ca"
+	DBSSLVerifyFull = "verify-full"
+)
+
+// Trace exporters.
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	// TracingOTLP sends spans over OTLP/HTTP to TracingOTLPEndpoint.
+	TracingOTLP = "otlp"
+)
+
+// Uniqueness policies for persons.
+const (
+	// UniquenessNone allows any number of persons with the same name.
+	UniquenessNone = "none"
+	// UniquenessStrict allows a single live person per case-insensitive
+	// name, surname and patronymic.
+	UniquenessStrict = "strict"
+)
+
+// Sources of settings, in increasing order of precedence.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceDotEnv  = "dotenv"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// Load reads the configuration from all sources. args are the command-line
+// arguments without the program name; those after the flags are returned
+// for the caller to interpret as a command. The config file is named by
+// --config or CONFIG_FILE. A .env file in the working directory, if there
+// is one, ranks between the config file and the environment; it is read
+// again on every Load. Secret settings are read from
+// the secret sources instead of the environment. Every invalid setting is
+// reported in the returned error, not just the first; the error is then an
+// *InvalidError that also carries the merged configuration.
+func Load(args []string) (*Config, []string, error) {
+	dotEnvVars, err := readDotEnv()
 	if err != nil {
-		return nil, err
-	}
-
-	return &Config{
-		ServerPort:        os.Getenv("SERVER_PORT"),
-		DBHost:            os.Getenv("DB_HOST"),
-		DBPort:            os.Getenv("DB_PORT"),
-		DBUser:            os.Getenv("DB_USER"),
-		DBPassword:        os.Getenv("DB_PASSWORD"),
-		DBName:            os.Getenv("DB_NAME"),
-		APIAgifyURL:       os.Getenv("API_AGIFY_URL"),
-		APIGenderizeURL:   os.Getenv("API_GENDERIZE_URL"),
-		APINationalizeURL: os.Getenv("API_NATIONALIZE_URL"),
-	}, nil
+		return nil, nil, err
+	}
+
+	fields := settings()
+	flagValues, configFile, rest, err := parseFlags(args, fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	if configFile == "" {
+		configFile = getenv("CONFIG_FILE", dotEnvVars)
+	}
+
+	values := make(map[string]string)
+	sources := make(map[string]string)
+	layer := func(source string, layerValues map[string]string) {
+		for key, value := range layerValues {
+			values[key] = value
+			sources[key] = source
+		}
+	}
+
+	defaults := make(map[string]string)
+	for _, f := range fields {
+		if f.hasDefault {
+			defaults[f.key] = f.def
+		}
+	}
+	layer(sourceDefault, defaults)
+	if configFile != "" {
+		fileValues, err := readFile(configFile, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		layer(sourceFile, fileValues)
+	}
+	layer(sourceDotEnv, envValues(fields, func(key string) string { return dotEnvVars[key] }))
+	layer(sourceEnv, envValues(fields, os.Getenv))
+	var errs []error
+	for _, f := range fields {
+		if !f.secret {
+			continue
+		}
+		value, source, err := resolveSecret(f.key, dotEnvVars)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if source != "" {
+			layer(source, map[string]string{f.key: value})
+		}
+	}
+	layer(sourceFlag, flagValues)
+
+	cfg := &Config{sources: sources, file: configFile, invalid: make(map[string]string)}
+	unparsed := make(map[string]bool)
+	for _, f := range fields {
+		value, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %v", f.key, err))
+			unparsed[f.key] = true
+			cfg.invalid[f.key] = value
+		}
+	}
+	errs = append(errs, cfg.validate(unparsed)...)
+	if len(errs) > 0 {
+		return nil, rest, &InvalidError{Config: cfg, Errs: errs}
+	}
+
+	dotEnv.Store(&dotEnvVars)
+
+	return cfg, rest, nil
+}
+
+// InvalidError reports every invalid setting found by Load.
+type InvalidError struct {
+	// Config is the merged configuration, invalid settings included, so
+	// that it can still be printed.
+	Config *Config
+	Errs   []error
+}
+
+func (e *InvalidError) Error() string {
+	return "invalid configuration:\n" + errors.Join(e.Errs...).Error()
+}
+
+func (e *InvalidError) Unwrap() []error {
+	return e.Errs
+}
+
+// File returns the path of the config file, or "" if there is none.
+func (c *Config) File() string {
+	return c.file
+}
+
+// Print writes the effective configuration as KEY=value lines, each
+// annotated with where its value came from. Secrets are masked. Settings
+// that failed to parse are shown as given.
+func (c *Config) Print(w io.Writer) error {
+	for _, f := range settings() {
+		value, invalid := c.invalid[f.key]
+		if !invalid {
+			value = f.get(c)
+		}
+		if f.secret && value != "" {
+			value = "******"
+		}
+		source := c.sources[f.key]
+		if source == "" {
+			source = "unset"
+		}
+		if invalid {
+			source += ", invalid"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\t# %s\n", f.key, value, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setting is a Config field described by its tags.
+type setting struct {
+	key        string
+	def        string
+	hasDefault bool
+	secret     bool
+	static     bool
+	index      int
+}
+
+func settings() []setting {
+	t := reflect.TypeOf(Config{})
+	var fields []setting
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("config")
+		if key == "" {
+			continue
+		}
+		def, hasDefault := field.Tag.Lookup("default")
+		fields = append(fields, setting{
+			key:        key,
+			def:        def,
+			hasDefault: hasDefault,
+			secret:     field.Tag.Get("secret") == "true",
+			static:     field.Tag.Get("static") == "true",
+			index:      i,
+		})
+	}
+	return fields
+}
+
+// fileKey and flagName are the names of a setting in the config file and
+// on the command line.
+func (f setting) fileKey() string {
+	return strings.ToLower(f.key)
+}
+
+func (f setting) flagName() string {
+	return strings.ReplaceAll(f.fileKey(), "_", "-")
+}
+
+// set parses value according to the field's type.
+func (f setting) set(cfg *Config, value string) error {
+	field := reflect.ValueOf(cfg).Elem().Field(f.index)
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(x)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// get formats the field's value the way set parses it.
+func (f setting) get(cfg *Config) string {
+	switch v := reflect.ValueOf(cfg).Elem().Field(f.index).Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
 }
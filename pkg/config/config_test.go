@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// isolate runs the test in an empty working directory with every setting
+// unset in the environment, so that only what the test writes is loaded.
+func isolate(t *testing.T) {
+	t.Helper()
+	for _, f := range settings() {
+		t.Setenv(f.key, "")
+		t.Setenv(f.key+"_FILE", "")
+	}
+	t.Setenv("CONFIG_FILE", "")
+	t.Chdir(t.TempDir())
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// requiredEnv sets the settings Load cannot do without.
+func requiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "persons")
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	// Each layer gives LOG_LEVEL a different valid level and ADMIN_TOKEN its
+	// own name.
+	levels := map[string]string{sourceFile: "warn", sourceDotEnv: "error", sourceEnv: "debug", sourceFlag: "trace"}
+
+	tests := []struct {
+		layers    []string
+		wantLevel string
+		wantToken string
+	}{
+		{layers: nil, wantLevel: sourceDefault},
+		{layers: []string{sourceFile}, wantLevel: sourceFile, wantToken: sourceFile},
+		{layers: []string{sourceFile, sourceDotEnv}, wantLevel: sourceDotEnv, wantToken: sourceDotEnv},
+		{layers: []string{sourceFile, sourceDotEnv, sourceEnv}, wantLevel: sourceEnv, wantToken: sourceEnv},
+		{layers: []string{sourceFile, sourceDotEnv, sourceEnv, "secret_file"}, wantLevel: sourceEnv, wantToken: "secret_file"},
+		{layers: []string{sourceDotEnv, "secret_file"}, wantLevel: sourceDotEnv, wantToken: "secret_file"},
+		{layers: []string{sourceFile, sourceDotEnv, sourceEnv, "secret_file", sourceFlag}, wantLevel: sourceFlag, wantToken: sourceFlag},
+		{layers: []string{sourceFile, sourceFlag}, wantLevel: sourceFlag, wantToken: sourceFlag},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.layers, "<"), func(t *testing.T) {
+			isolate(t)
+			requiredEnv(t)
+			var args []string
+			for _, layer := range tt.layers {
+				switch layer {
+				case sourceFile:
+					writeFile(t, "config.yaml", "log_level: "+levels[layer]+"\nadmin_token: "+layer+"\n")
+					args = append(args, "--config", "config.yaml")
+				case sourceDotEnv:
+					writeFile(t, ".env", "LOG_LEVEL="+levels[layer]+"\nADMIN_TOKEN="+layer+"\n")
+				case sourceEnv:
+					t.Setenv("LOG_LEVEL", levels[layer])
+					t.Setenv("ADMIN_TOKEN", layer)
+				case "secret_file":
+					writeFile(t, "admin_token", layer+"\n")
+					t.Setenv("ADMIN_TOKEN_FILE", "admin_token")
+				case sourceFlag:
+					args = append(args, "--log-level", levels[layer], "--admin-token", layer)
+				}
+			}
+
+			cfg, _, err := Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantLevel := levels[tt.wantLevel]
+			if tt.wantLevel == sourceDefault {
+				wantLevel = "info"
+			}
+			if cfg.LogLevel != wantLevel || cfg.sources["LOG_LEVEL"] != tt.wantLevel {
+				t.Errorf("LOG_LEVEL = %q from %q, want %q from %q", cfg.LogLevel, cfg.sources["LOG_LEVEL"], wantLevel, tt.wantLevel)
+			}
+			if cfg.AdminToken != tt.wantToken || cfg.sources["ADMIN_TOKEN"] != tt.wantToken {
+				t.Errorf("ADMIN_TOKEN = %q from %q, want it from %q", cfg.AdminToken, cfg.sources["ADMIN_TOKEN"], tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoadReportsEveryError(t *testing.T) {
+	isolate(t)
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("SERVER_PORT", "http")
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	t.Setenv("LOG_LEVEL", "loud")
+
+	_, _, err := Load([]string{"--db-sslcert", "client.pem"})
+	if err == nil {
+		t.Fatal("Load accepted an invalid configuration")
+	}
+	for _, key := range []string{"SERVER_PORT", "DB_USER", "DB_NAME", "DB_MAX_OPEN_CONNS", "DB_SSLCERT", "LOG_LEVEL"} {
+		if !strings.Contains(err.Error(), "invalid "+key+":") {
+			t.Errorf("error does not mention %s:\n%v", key, err)
+		}
+	}
+	if n := strings.Count(err.Error(), "invalid DB_MAX_OPEN_CONNS"); n != 1 {
+		t.Errorf("DB_MAX_OPEN_CONNS reported %d times, want once", n)
+	}
+}
+
+func TestPrintInvalidConfig(t *testing.T) {
+	isolate(t)
+	requiredEnv(t)
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	t.Setenv("DB_PASSWORD", "hunter2")
+
+	_, rest, err := Load([]string{"config", "print"})
+	var invalid *InvalidError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load error = %v, want *InvalidError", err)
+	}
+	if !slices.Equal(rest, []string{"config", "print"}) {
+		t.Errorf("rest = %q, want the command", rest)
+	}
+	var out strings.Builder
+	if err := invalid.Config.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"LOG_LEVEL=loud\t# env\n",
+		"DB_MAX_OPEN_CONNS=many\t# env, invalid\n",
+		"DB_HOST=localhost\t# env\n",
+		"DB_PASSWORD=******\t# env\n",
+		"SERVER_PORT=8080\t# default\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	isolate(t)
+	requiredEnv(t)
+	valid, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{name: "defaults", change: func(c *Config) {}},
+		{name: "DATABASE_URL replaces the discrete settings", change: func(c *Config) {
+			c.DatabaseURL = "postgres://app@db/persons"
+			c.DBHost, c.DBUser, c.DBName = "", "", ""
+		}},
+		{name: "DATABASE_URL of another database", change: func(c *Config) { c.DatabaseURL = "mysql://db/persons" }, want: []string{"DATABASE_URL"}},
+		{name: "missing host", change: func(c *Config) { c.DBHost = "" }, want: []string{"DB_HOST"}},
+		{name: "port out of range", change: func(c *Config) { c.DBPort = "70000" }, want: []string{"DB_PORT"}},
+		{name: "unknown sslmode", change: func(c *Config) { c.DBSSLMode = "prefer" }, want: []string{"DB_SSLMODE"}},
+		{name: "certificate without key", change: func(c *Config) { c.DBSSLCert = "client.pem" }, want: []string{"DB_SSLCERT"}},
+		{name: "provider URL without scheme", change: func(c *Config) { c.APIAgifyURL = "api.agify.io" }, want: []string{"API_AGIFY_URL"}},
+		{name: "zero batch size", change: func(c *Config) { c.BatchMaxItems = 0 }, want: []string{"BATCH_MAX_ITEMS"}},
+		{name: "zero purge interval", change: func(c *Config) { c.PurgeInterval = 0 }, want: []string{"PURGE_INTERVAL"}},
+		{name: "negative drain", change: func(c *Config) { c.ShutdownDrain = -1 }, want: []string{"SHUTDOWN_DRAIN"}},
+		{name: "unknown policy", change: func(c *Config) { c.UniquenessPolicy = "loose" }, want: []string{"UNIQUENESS_POLICY"}},
+		{name: "unknown critical dependency", change: func(c *Config) { c.HealthCritical = []string{"agify", "openai"} }, want: []string{"HEALTH_CRITICAL_DEPENDENCIES"}},
+		{name: "OTLP endpoint checked only for OTLP", change: func(c *Config) { c.TracingOTLPEndpoint = "collector" }},
+		{name: "bad OTLP endpoint", change: func(c *Config) {
+			c.TracingExporter = TracingOTLP
+			c.TracingOTLPEndpoint = "collector"
+		}, want: []string{"TRACING_OTLP_ENDPOINT"}},
+		{name: "sample ratio above one", change: func(c *Config) { c.TracingSampleRatio = 1.5 }, want: []string{"TRACING_SAMPLE_RATIO"}},
+		{name: "several at once", change: func(c *Config) {
+			c.ServerPort = "0"
+			c.LogFormat = "xml"
+		}, want: []string{"SERVER_PORT", "LOG_FORMAT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *valid
+			tt.change(&cfg)
+
+			var got []string
+			for _, err := range cfg.validate(nil) {
+				key, _, _ := strings.Cut(strings.TrimPrefix(err.Error(), "invalid "), ":")
+				got = append(got, key)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("validate reported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadRereadsDotEnv(t *testing.T) {
+	isolate(t)
+	const required = "DB_HOST=localhost\nDB_USER=app\nDB_NAME=persons\n"
+	writeFile(t, ".env", required+"LOG_LEVEL=debug\nADMIN_TOKEN=first\n")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "debug" || cfg.sources["LOG_LEVEL"] != sourceDotEnv {
+		t.Fatalf("LOG_LEVEL = %q from %q, want debug from .env", cfg.LogLevel, cfg.sources["LOG_LEVEL"])
+	}
+	store := NewStore(cfg, nil)
+
+	writeFile(t, ".env", required+"LOG_LEVEL=warn\nADMIN_TOKEN=second\n")
+	if _, err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Current(); got.LogLevel != "warn" || got.AdminToken != "second" {
+		t.Errorf("after editing .env: LOG_LEVEL = %q, ADMIN_TOKEN = %q; want warn, second", got.LogLevel, got.AdminToken)
+	}
+
+	writeFile(t, ".env", required)
+	if _, err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Current(); got.LogLevel != "info" || got.AdminToken != "" {
+		t.Errorf("after removing from .env: LOG_LEVEL = %q, ADMIN_TOKEN = %q; want the defaults", got.LogLevel, got.AdminToken)
+	}
+
+	t.Setenv("LOG_LEVEL", "error")
+	writeFile(t, ".env", required+"LOG_LEVEL=debug\n")
+	if _, err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Current().LogLevel; got != "error" {
+		t.Errorf("LOG_LEVEL = %q, want error: the environment overrides .env", got)
+	}
+}
+
+func TestRejectedReloadKeepsSecrets(t *testing.T) {
+	isolate(t)
+	const required = "DB_HOST=localhost\nDB_USER=app\nDB_NAME=persons\n"
+	writeFile(t, "old_password", "old\n")
+	writeFile(t, "new_password", "new\n")
+	writeFile(t, ".env", required+"ADMIN_TOKEN=old\nDB_PASSWORD_FILE=old_password\n")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(cfg, nil)
+
+	writeFile(t, ".env", required+"ADMIN_TOKEN=new\nDB_PASSWORD_FILE=new_password\nLOG_LEVEL=loud\n")
+	if _, err := store.Reload(); err == nil {
+		t.Fatal("Reload accepted an invalid LOG_LEVEL")
+	}
+
+	refreshed, err := store.Current().RefreshSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AdminToken != "old" || refreshed.DBPassword != "old" {
+		t.Errorf("after a rejected reload: ADMIN_TOKEN = %q, DB_PASSWORD = %q; want both old",
+			refreshed.AdminToken, refreshed.DBPassword)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretSource supplies the values of secret settings, those tagged secret
+// in Config. Secret sources take the place of the environment for them:
+// they override the config file and are overridden by flags.
+type SecretSource interface {
+	// Name says where secrets come from; config print shows it as the
+	// source of the setting.
+	Name() string
+	// Secret returns the value of the setting key, or ok false if the
+	// source does not have it.
+	Secret(key string) (value string, ok bool, err error)
+}
+
+// FileSecrets reads a secret from the file named by the environment
+// variable of its key with a _FILE suffix, such as DB_PASSWORD_FILE, as
+// Docker and Kubernetes mount secrets. The zero value only looks in the
+// environment; the built-in source also looks in .env. A trailing newline is
+// dropped.
+type FileSecrets struct {
+	dotEnv map[string]string
+}
+
+func (FileSecrets) Name() string {
+	return "secret_file"
+}
+
+func (s FileSecrets) Secret(key string) (string, bool, error) {
+	path := getenv(key+"_FILE", s.dotEnv)
+	if path == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s_FILE: %v", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// EnvSecrets reads a secret from the environment variable of its key;
+// empty variables count as unset.
+type EnvSecrets struct{}
+
+func (EnvSecrets) Name() string {
+	return sourceEnv
+}
+
+func (EnvSecrets) Secret(key string) (string, bool, error) {
+	value := os.Getenv(key)
+	return value, value != "", nil
+}
+
+// dotEnvSecrets reads a secret from the variables of .env, below the
+// environment.
+type dotEnvSecrets map[string]string
+
+func (dotEnvSecrets) Name() string {
+	return sourceDotEnv
+}
+
+func (s dotEnvSecrets) Secret(key string) (string, bool, error) {
+	value := s[key]
+	return value, value != "", nil
+}
+
+var (
+	secretSourcesMu sync.Mutex
+	// secretSources are the sources added with AddSecretSource; the
+	// built-in ones follow them.
+	secretSources []SecretSource
+)
+
+// AddSecretSource makes Load and RefreshSecrets consult src, such as a
+// vault client, ahead of the built-in sources: FileSecrets, EnvSecrets and
+// .env.
+func AddSecretSource(src SecretSource) {
+	secretSourcesMu.Lock()
+	defer secretSourcesMu.Unlock()
+	secretSources = append([]SecretSource{src}, secretSources...)
+}
+
+// resolveSecret returns the value of the secret setting key from the first
+// source that has it and that source's name, or an empty name if none has.
+// dotEnvVars are the variables of .env the built-in sources consult.
+func resolveSecret(key string, dotEnvVars map[string]string) (value, source string, err error) {
+	secretSourcesMu.Lock()
+	sources := append([]SecretSource(nil), secretSources...)
+	secretSourcesMu.Unlock()
+	sources = append(sources, FileSecrets{dotEnv: dotEnvVars}, EnvSecrets{}, dotEnvSecrets(dotEnvVars))
+
+	for _, src := range sources {
+		value, ok, err := src.Secret(key)
+		if err != nil {
+			return "", "", err
+		}
+		if ok {
+			return value, src.Name(), nil
+		}
+	}
+	return "", "", nil
+}
+
+// RefreshSecrets returns a copy of c with the secrets that came from a
+// secret source read again, so that rotated credentials are picked up.
+// Secrets set in the config file or by a flag keep their values, and .env
+// is read as of the last Load that succeeded.
+func (c *Config) RefreshSecrets() (*Config, error) {
+	next := *c
+	for _, f := range settings() {
+		if !c.fromSecretSource(f) {
+			continue
+		}
+		value, source, err := resolveSecret(f.key, acceptedDotEnv())
+		if err != nil {
+			return nil, err
+		}
+		if source == "" {
+			continue
+		}
+		if err := f.set(&next, value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", f.key, err)
+		}
+	}
+	return &next, nil
+}
+
+// fromSecretSource reports whether the value of f came from a secret
+// source.
+func (c *Config) fromSecretSource(f setting) bool {
+	if !f.secret {
+		return false
+	}
+	switch c.sources[f.key] {
+	case "", sourceDefault, sourceFile, sourceFlag:
+		return false
+	}
+	return true
+}
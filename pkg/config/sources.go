@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// dotEnv holds the variables of .env as of the last Load that succeeded,
+// for RefreshSecrets. They are kept apart from the process environment, so
+// that a reload sees the file as edited, including variables removed from
+// it, and a rejected reload leaves the accepted ones in place.
+var dotEnv atomic.Pointer[map[string]string]
+
+// readDotEnv reads the variables of .env in the working directory.
+// Deployments configured through the real environment have no .env, which
+// is fine.
+func readDotEnv() (map[string]string, error) {
+	values, err := godotenv.Read()
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .env: %v", err)
+	}
+	return values, nil
+}
+
+// acceptedDotEnv returns the variables of .env published by the last Load
+// that succeeded.
+func acceptedDotEnv() map[string]string {
+	if vars := dotEnv.Load(); vars != nil {
+		return *vars
+	}
+	return nil
+}
+
+// getenv returns the environment variable key, falling back to the
+// variables read from .env.
+func getenv(key string, dotEnvVars map[string]string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return dotEnvVars[key]
+}
+
+// envValues returns the settings found by lookup, either in the environment
+// or in .env; empty variables count as unset. Secrets are left to the secret
+// sources.
+func envValues(fields []setting, lookup func(key string) string) map[string]string {
+	values := make(map[string]string)
+	for _, f := range fields {
+		if f.secret {
+			continue
+		}
+		if value := lookup(f.key); value != "" {
+			values[f.key] = value
+		}
+	}
+	return values
+}
+
+// parseFlags parses a flag per setting plus --config. Only the flags given
+// on the command line are returned.
+func parseFlags(args []string, fields []setting) (map[string]string, string, []string, error) {
+	fs := flag.NewFlagSet("person-service", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (or CONFIG_FILE)")
+	byFlag := make(map[string]string, len(fields))
+	for _, f := range fields {
+		usage := "overrides " + f.key
+		if f.hasDefault {
+			usage += " (default " + f.def + ")"
+		}
+		fs.String(f.flagName(), "", usage)
+		byFlag[f.flagName()] = f.key
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", nil, err
+	}
+
+	values := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		if key, ok := byFlag[fl.Name]; ok {
+			values[key] = fl.Value.String()
+		}
+	})
+	return values, *configFile, fs.Args(), nil
+}
+
+// readFile reads a flat YAML or TOML file of settings, chosen by the file
+// extension. Lists may be given as arrays or comma-separated strings.
+func readFile(path string, fields []setting) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s: expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	byFileKey := make(map[string]string, len(fields))
+	for _, f := range fields {
+		byFileKey[f.fileKey()] = f.key
+	}
+
+	values := make(map[string]string)
+	var errs []error
+	for fileKey, value := range raw {
+		key, ok := byFileKey[fileKey]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown setting %q in %s", fileKey, path))
+			continue
+		}
+		values[key] = fileValue(value)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return values, nil
+}
+
+func fileValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds the current configuration and replaces it on Reload. Readers
+// take a snapshot with Current and must not keep it longer than they need
+// a consistent view.
+type Store struct {
+	args    []string
+	current atomic.Pointer[Config]
+
+	// mu serializes reloads and guards onReload.
+	mu       sync.Mutex
+	onReload []func(cfg *Config)
+}
+
+// NewStore returns a store holding cfg, which Load returned for args.
+func NewStore(cfg *Config, args []string) *Store {
+	s := &Store{args: args}
+	s.current.Store(cfg)
+	return s
+}
+
+// Current returns the configuration in effect.
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// OnReload registers fn to be called with every configuration Reload
+// accepts.
+func (s *Store) OnReload(fn func(cfg *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onReload = append(s.onReload, fn)
+}
+
+// Reload loads the configuration again from the same sources. An invalid
+// configuration is rejected and the current one kept. Static settings,
+// which only take effect at startup, keep their current values; the keys
+// of those that changed are returned so the caller can tell that they need
+// a restart. Secrets from a secret source are left out, since whoever uses
+// them picks up rotated values with RefreshSecrets.
+func (s *Store) Reload() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, _, err := Load(s.args)
+	if err != nil {
+		return nil, err
+	}
+
+	current := s.Current()
+	var ignored []string
+	for _, f := range settings() {
+		if !f.static || f.get(next) == f.get(current) {
+			continue
+		}
+		reflect.ValueOf(next).Elem().Field(f.index).Set(reflect.ValueOf(current).Elem().Field(f.index))
+		next.sources[f.key] = current.sources[f.key]
+		if !current.fromSecretSource(f) {
+			ignored = append(ignored, f.key)
+		}
+	}
+
+	s.current.Store(next)
+	for _, fn := range s.onReload {
+		fn(next)
+	}
+	return ignored, nil
+}
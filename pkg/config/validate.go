@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// validate checks the settings against each other and their allowed
+// ranges, returning every problem found. Settings in skip already failed to
+// parse and are not reported again.
+func (c *Config) validate(skip map[string]bool) []error {
+	v := &validator{skip: skip}
+
+	v.port("SERVER_PORT", c.ServerPort)
+	if c.DatabaseURL != "" {
+		if u, err := url.Parse(c.DatabaseURL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			v.fail("DATABASE_URL", "must be a postgres:// URL")
+		}
+	} else {
+		v.required("DB_HOST", c.DBHost)
+		v.port("DB_PORT", c.DBPort)
+		v.required("DB_USER", c.DBUser)
+		v.required("DB_NAME", c.DBName)
+	}
+	if c.DBSSLMode != "" {
+		v.oneOf("DB_SSLMODE", c.DBSSLMode, DBSSLDisable, DBSSLRequire, DBSSLVerifyCA, DBSSLVerifyFull)
+	}
+	if (c.DBSSLCert == "") != (c.DBSSLKey == "") {
+		v.fail("DB_SSLCERT", "DB_SSLCERT and DB_SSLKEY must be set together")
+	}
+	v.atLeast("DB_MAX_OPEN_CONNS", c.DBMaxOpenConns, 0)
+	v.atLeast("DB_MAX_IDLE_CONNS", c.DBMaxIdleConns, 0)
+	v.notNegative("DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime)
+	v.notNegative("DB_CONN_MAX_IDLE_TIME", c.DBConnMaxIdleTime)
+	v.notNegative("DB_CONNECT_TIMEOUT", c.DBConnectTimeout)
+	v.atLeast("DB_CONNECT_RETRIES", c.DBConnectRetries, 0)
+	v.positive("DB_CONNECT_BACKOFF", c.DBConnectBackoff)
+	v.notNegative("DB_STATEMENT_TIMEOUT", c.DBStatementTimeout)
+	v.url("API_AGIFY_URL", c.APIAgifyURL)
+	v.url("API_GENDERIZE_URL", c.APIGenderizeURL)
+	v.url("API_NATIONALIZE_URL", c.APINationalizeURL)
+
+	v.atLeast("SOFT_DELETE_RETENTION_DAYS", c.SoftDeleteRetentionDays, 0)
+	v.atLeast("BATCH_MAX_ITEMS", c.BatchMaxItems, 1)
+	v.atLeast("IMPORT_MAX_BYTES", c.ImportMaxBytes, 1)
+	v.atLeast("IMPORT_ASYNC_ROWS", c.ImportAsyncRows, 0)
+	v.atLeast("DB_TX_MAX_RETRIES", c.DBTxMaxRetries, 0)
+	v.atLeast("ENRICH_BREAKER_THRESHOLD", c.EnrichBreakerThreshold, 0)
+	v.atLeast("ENRICH_CACHE_SIZE", c.EnrichCacheSize, 0)
+
+	v.positive("PURGE_INTERVAL", c.PurgeInterval)
+	v.positive("ENRICH_TIMEOUT", c.EnrichTimeout)
+	v.positive("IMPORT_JOB_TTL", c.ImportJobTTL)
+	v.positive("IDEMPOTENCY_KEY_TTL", c.IdempotencyKeyTTL)
+	v.positive("IDEMPOTENCY_LOCK_TIMEOUT", c.IdempotencyLockTimeout)
+	v.positive("MIGRATE_LOCK_TIMEOUT", c.MigrateLockTimeout)
+	v.positive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.positive("ENRICH_BREAKER_COOLDOWN", c.EnrichBreakerCooldown)
+	v.positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+	v.notNegative("HEALTH_PROBE_INTERVAL", c.HealthProbeInterval)
+	// Zero disables these.
+	v.notNegative("HTTP_READ_HEADER_TIMEOUT", c.HTTPReadHeaderTimeout)
+	v.notNegative("HTTP_READ_TIMEOUT", c.HTTPReadTimeout)
+	v.notNegative("HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout)
+	v.notNegative("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout)
+	v.notNegative("SHUTDOWN_DRAIN", c.ShutdownDrain)
+	v.notNegative("ENRICH_CACHE_TTL", c.EnrichCacheTTL)
+	v.notNegative("CONFIG_RELOAD_INTERVAL", c.ConfigReloadInterval)
+
+	v.oneOf("UNIQUENESS_POLICY", c.UniquenessPolicy, UniquenessNone, UniquenessStrict)
+	v.oneOf("DB_TX_ISOLATION", c.DBTxIsolation, "read_committed", "repeatable_read", "serializable")
+	for _, name := range c.HealthCritical {
+		v.oneOf("HEALTH_CRITICAL_DEPENDENCIES", name, "agify", "genderize", "nationalize")
+	}
+	v.oneOf("TRACING_EXPORTER", c.TracingExporter, TracingNone, TracingStdout, TracingOTLP)
+	if c.TracingExporter == TracingOTLP {
+		v.url("TRACING_OTLP_ENDPOINT", c.TracingOTLPEndpoint)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.fail("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %v", c.TracingSampleRatio)
+	}
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		v.fail("LOG_LEVEL", "%v", err)
+	}
+	v.oneOf("LOG_FORMAT", c.LogFormat, LogFormatJSON, LogFormatText)
+
+	return v.errs
+}
+
+// validator collects validation errors.
+type validator struct {
+	skip map[string]bool
+	errs []error
+}
+
+func (v *validator) fail(key, format string, args ...interface{}) {
+	if v.skip[key] {
+		return
+	}
+	v.errs = append(v.errs, fmt.Errorf("invalid %s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.fail(key, "required")
+	}
+}
+
+func (v *validator) port(key, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.fail(key, "must be a port number, got %q", value)
+	}
+}
+
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.fail(key, "must be an http or https URL, got %q", value)
+	}
+}
+
+func (v *validator) atLeast(key string, value, min int) {
+	if value < min {
+		v.fail(key, "must be at least %d, got %d", min, value)
+	}
+}
+
+func (v *validator) positive(key string, value time.Duration) {
+	if value <= 0 {
+		v.fail(key, "must be positive, got %s", value)
+	}
+}
+
+func (v *validator) notNegative(key string, value time.Duration) {
+	if value < 0 {
+		v.fail(key, "must not be negative, got %s", value)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(key, "%q is not one of %v", value, allowed)
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/Mukam21/server_Golang/pkg/requestctx"
+)
+
+// insertAudit records the change from before to after in person_audit using
+// the caller's transaction, so the audit row commits or rolls back with it.
+func insertAudit(ctx context.Context, tx *sql.Tx, op model.AuditOperation, before, after *model.Person) error {
+	changes := model.DiffPersons(before, after)
+	return insertAuditChanges(ctx, tx, op, auditSubject(before, after), changes)
+}
+
+// insertAuditChanges writes an audit row together with a snapshot of the
+// subject, which point-in-time reads and reverts are served from.
+func insertAuditChanges(ctx context.Context, tx *sql.Tx, op model.AuditOperation, subject *model.Person, changes model.FieldChanges) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(subject)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO person_audit (person_id, operation, actor, request_id, version, changes, snapshot)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.ExecContext(ctx, query,
+		subject.ID,
+		op,
+		nullString(requestctx.Actor(ctx)),
+		nullString(requestctx.RequestID(ctx)),
+		subject.Version,
+		data,
+		snapshot,
+	)
+	return err
+}
+
+// auditSubject picks the state whose id and version an audit row refers to.
+func auditSubject(before, after *model.Person) *model.Person {
+	if after != nil {
+		return after
+	}
+	return before
+}
+
+func (r *Repository) History(ctx context.Context, personID int64, page, limit int) ([]*model.AuditEntry, error) {
+	query := `
+        SELECT id, person_id, operation, actor, request_id, version, changes, changed_at
+        FROM person_audit
+        WHERE person_id = $1
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3`
+
+	rows, err := r.conn().QueryContext(ctx, query, personID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*model.AuditEntry
+	for rows.Next() {
+		entry := &model.AuditEntry{}
+		var changes []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.PersonID,
+			&entry.Operation,
+			&entry.Actor,
+			&entry.RequestID,
+			&entry.Version,
+			&changes,
+			&entry.ChangedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// GetAsOf returns the person as recorded by the last audit entry at or before
+// asOf. Persons that were purged by then are reported as not found.
+func (r *Repository) GetAsOf(ctx context.Context, id int64, asOf time.Time) (*model.Person, error) {
+	query := `
+        SELECT operation, snapshot
+        FROM person_audit
+        WHERE person_id = $1 AND changed_at <= $2
+        ORDER BY id DESC
+        LIMIT 1`
+
+	var op model.AuditOperation
+	var snapshot []byte
+	err := r.conn().QueryRowContext(ctx, query, id, asOf).Scan(&op, &snapshot)
+	if err == sql.ErrNoRows {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if op == model.AuditPurge {
+		return nil, model.ErrNotFound
+	}
+
+	return decodeSnapshot(snapshot)
+}
+
+// Revert restores the fields recorded at the given version as a new version
+// of the person. Reverting to a state in which the person was deleted deletes
+// it again as of now, so that the retention period starts over, and
+// reverting to a live state restores it.
+func (r *Repository) Revert(ctx context.Context, id, version, expectedVersion int64) (*model.Person, error) {
+	snapshotQuery := `
+        SELECT snapshot
+        FROM person_audit
+        WHERE person_id = $1 AND version = $2
+        ORDER BY id DESC
+        LIMIT 1`
+	updateQuery := `
+        UPDATE persons
+        SET name = $1, surname = $2, patronymic = $3, age = $4, gender = $5, nationality = $6,
+            deleted_at = CASE WHEN $7 THEN CURRENT_TIMESTAMP END, name_key = $8, natural_key = $9, version = version + 1
+        WHERE id = $10 AND version = $11
+        RETURNING ` + personColumns
+
+	var person *model.Person
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockPerson(ctx, tx, id, expectedVersion, true)
+		if err != nil {
+			return err
+		}
+
+		var snapshot []byte
+		err = tx.QueryRowContext(ctx, snapshotQuery, id, version).Scan(&snapshot)
+		if err == sql.ErrNoRows {
+			return model.ErrNoSnapshot
+		}
+		if err != nil {
+			return err
+		}
+		target, err := decodeSnapshot(snapshot)
+		if err != nil {
+			return err
+		}
+
+		nameKey, naturalKey := r.keys(target)
+		person, err = scanPerson(tx.QueryRowContext(ctx, updateQuery,
+			target.Name,
+			target.Surname,
+			target.Patronymic,
+			target.Age,
+			target.Gender,
+			target.Nationality,
+			target.DeletedAt != nil,
+			nameKey,
+			naturalKey,
+			id,
+			before.Version,
+		))
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, model.AuditRevert, before, person)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return person, nil
+}
+
+func decodeSnapshot(snapshot []byte) (*model.Person, error) {
+	if snapshot == nil {
+		return nil, model.ErrNoSnapshot
+	}
+	person := &model.Person{}
+	if err := json.Unmarshal(snapshot, person); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/tracing"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// maxConnectBackoff caps the wait between connection attempts at startup.
+const maxConnectBackoff = 30 * time.Second
+
+// NewPostgresDB opens the connection pool and waits for the database to
+// answer, retrying up to DBConnectRetries times with exponential backoff so
+// the service survives starting before its database. Every new connection
+// reads the credentials from their secret source again, so rotated
+// passwords are picked up as the pool replaces its connections.
+func NewPostgresDB(cfg *config.Config, log *logrus.Logger) (*sql.DB, error) {
+	if _, err := DSN(cfg); err != nil {
+		return nil, err
+	}
+
+	db := otelsql.OpenDB(&connector{cfg: cfg}, tracing.SQLOptions()...)
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+	backoff := cfg.DBConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(context.Background())
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= cfg.DBConnectRetries {
+			db.Close()
+			return nil, err
+		}
+
+		log.Warnf("Database is not reachable, retrying in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// connector opens connections with the DSN of the configuration as it is
+// at the time, secrets refreshed.
+type connector struct {
+	cfg *config.Config
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cfg, err := c.cfg.RefreshSecrets()
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return pc.Connect(ctx)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// DSN builds the lib/pq connection string from DATABASE_URL, or from the
+// discrete DB_* settings when it is not set. The TLS, connect timeout and
+// statement timeout settings that are given override those in the URL.
+func DSN(cfg *config.Config) (string, error) {
+	var params dsnParams
+	if cfg.DatabaseURL != "" {
+		if err := params.setURL(cfg.DatabaseURL); err != nil {
+			return "", fmt.Errorf("invalid DATABASE_URL: %v", err)
+		}
+	} else {
+		params.set("host", cfg.DBHost)
+		params.set("port", cfg.DBPort)
+		params.set("user", cfg.DBUser)
+		params.set("password", cfg.DBPassword)
+		params.set("dbname", cfg.DBName)
+		params.set("sslmode", config.DBSSLDisable)
+	}
+
+	overrides := []struct{ key, value string }{
+		{"sslmode", cfg.DBSSLMode},
+		{"sslrootcert", cfg.DBSSLRootCert},
+		{"sslcert", cfg.DBSSLCert},
+		{"sslkey", cfg.DBSSLKey},
+	}
+	for _, o := range overrides {
+		if o.value != "" {
+			params.set(o.key, o.value)
+		}
+	}
+	if cfg.DBConnectTimeout > 0 {
+		// Whole seconds, rounded up: lib/pq reads 0 as no timeout.
+		seconds := int(math.Ceil(cfg.DBConnectTimeout.Seconds()))
+		params.set("connect_timeout", strconv.Itoa(seconds))
+	}
+	if cfg.DBStatementTimeout > 0 {
+		params.set("statement_timeout", strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10))
+	}
+
+	return params.String(), nil
+}
+
+// dsnParams collects connection parameters in the order they were first
+// set, each key once with its latest value.
+type dsnParams struct {
+	keys   []string
+	values map[string]string
+}
+
+func (p *dsnParams) set(key, value string) {
+	if p.values == nil {
+		p.values = make(map[string]string)
+	}
+	if _, ok := p.values[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+	p.values[key] = value
+}
+
+// setURL sets the parameters given by a postgres:// URL, as pq.ParseURL
+// reads them: empty values are left out and the rest are in key order.
+func (p *dsnParams) setURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("invalid connection protocol: %s", u.Scheme)
+	}
+
+	values := make(map[string]string)
+	if u.User != nil {
+		values["user"] = u.User.Username()
+		values["password"], _ = u.User.Password()
+	}
+	if host, port, err := net.SplitHostPort(u.Host); err != nil {
+		values["host"] = u.Host
+	} else {
+		values["host"] = host
+		values["port"] = port
+	}
+	if u.Path != "" {
+		values["dbname"] = u.Path[1:]
+	}
+	query := u.Query()
+	for key := range query {
+		values[key] = query.Get(key)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if values[key] != "" {
+			p.set(key, values[key])
+		}
+	}
+	return nil
+}
+
+// String formats the parameters as key='value' pairs, quoting the values
+// as lib/pq expects.
+func (p *dsnParams) String() string {
+	pairs := make([]string, len(p.keys))
+	for i, key := range p.keys {
+		value := strings.ReplaceAll(p.values[key], `\`, `\\`)
+		value = strings.ReplaceAll(value, `'`, `\'`)
+		pairs[i] = fmt.Sprintf("%s='%s'", key, value)
+	}
+	return strings.Join(pairs, " ")
+}
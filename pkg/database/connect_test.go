@@ -0,0 +1,81 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "discrete settings without TLS",
+			cfg:  config.Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: "secret", DBName: "persons"},
+			want: "host='db' port='5432' user='app' password='secret' dbname='persons' sslmode='disable'",
+		},
+		{
+			name: "quotes and backslashes are escaped",
+			cfg:  config.Config{DBHost: "db", DBUser: "app", DBPassword: `it's\x`, DBName: "persons"},
+			want: `host='db' port='' user='app' password='it\'s\\x' dbname='persons' sslmode='disable'`,
+		},
+		{
+			name: "TLS and timeouts override",
+			cfg: config.Config{
+				DBHost: "db", DBPort: "5432", DBUser: "app", DBName: "persons",
+				DBSSLMode: config.DBSSLVerifyFull, DBSSLRootCert: "/ca.pem",
+				DBConnectTimeout: 1500 * time.Millisecond, DBStatementTimeout: 30 * time.Second,
+			},
+			want: "host='db' port='5432' user='app' password='' dbname='persons' sslmode='verify-full' " +
+				"sslrootcert='/ca.pem' connect_timeout='2' statement_timeout='30000'",
+		},
+		{
+			name: "DATABASE_URL",
+			cfg:  config.Config{DatabaseURL: "postgres://app:secret@db:5433/persons?sslmode=require", DBHost: "ignored"},
+			want: "dbname='persons' host='db' password='secret' port='5433' sslmode='require' user='app'",
+		},
+		{
+			name: "DATABASE_URL with an override",
+			cfg:  config.Config{DatabaseURL: "postgres://app@db/persons?sslmode=require", DBSSLMode: config.DBSSLDisable},
+			want: "dbname='persons' host='db' sslmode='disable' user='app'",
+		},
+		{
+			name: "DATABASE_URL with overridden timeouts",
+			cfg: config.Config{
+				DatabaseURL:      "postgres://app@db/persons?connect_timeout=10&statement_timeout=1000",
+				DBConnectTimeout: 3 * time.Second, DBStatementTimeout: time.Minute,
+			},
+			want: "connect_timeout='3' dbname='persons' host='db' statement_timeout='60000' user='app'",
+		},
+		{
+			name:    "invalid DATABASE_URL",
+			cfg:     config.Config{DatabaseURL: "mysql://db/persons"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DSN(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DSN error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DSN =\n%s\nwant\n%s", got, tt.want)
+			}
+			seen := make(map[string]bool)
+			for _, pair := range strings.Fields(got) {
+				key, _, _ := strings.Cut(pair, "=")
+				if seen[key] {
+					t.Errorf("DSN sets %s more than once: %s", key, got)
+				}
+				seen[key] = true
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// DuplicateCandidates returns pairs of persons whose surnames are similar by
+// trigram, as candidates for duplicate scoring. Each person is paired with at
+// most neighbors persons with the nearest surnames, found on the trigram
+// index, so the scan grows with the table rather than with its square.
+// A non-zero personID only looks at the neighbors of that person. At most
+// limit pairs are returned, and truncated reports whether there were more.
+func (r *Repository) DuplicateCandidates(ctx context.Context, personID int64, neighbors, limit int) (_ [][2]*model.Person, truncated bool, _ error) {
+	query := "SELECT " + qualifiedColumns("a") + ", " + qualifiedColumns("b") + `
+        FROM persons a
+        CROSS JOIN LATERAL (
+            SELECT ` + personColumns + `
+            FROM persons
+            WHERE deleted_at IS NULL AND id <> a.id
+            ORDER BY lower(surname) <-> lower(a.surname)
+            LIMIT $2
+        ) b
+        WHERE a.deleted_at IS NULL
+          AND ($1 = 0 OR a.id = $1)
+          AND lower(a.surname) % lower(b.surname)
+        ORDER BY a.id, similarity(lower(a.surname), lower(b.surname)) DESC, b.id
+        LIMIT $3`
+
+	rows, err := r.conn().QueryContext(ctx, query, personID, neighbors, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	// Two persons among each other's neighbors come up twice.
+	seen := make(map[[2]int64]bool)
+	var pairs [][2]*model.Person
+	for n := 0; rows.Next(); n++ {
+		if n == limit {
+			truncated = true
+			break
+		}
+		a, b := &model.Person{}, &model.Person{}
+		if err := rows.Scan(append(personFields(a), personFields(b)...)...); err != nil {
+			return nil, false, err
+		}
+		if a.ID > b.ID {
+			a, b = b, a
+		}
+		if seen[[2]int64{a.ID, b.ID}] {
+			continue
+		}
+		seen[[2]int64{a.ID, b.ID}] = true
+		pairs = append(pairs, [2]*model.Person{a, b})
+	}
+
+	return pairs, truncated, rows.Err()
+}
+
+func qualifiedColumns(alias string) string {
+	columns := strings.Split(personColumns, ", ")
+	for i, column := range columns {
+		columns[i] = alias + "." + column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Merge folds the duplicate into the survivor as req asks and soft-deletes
+// the duplicate, recording a merge entry in the history of both. A non-zero
+// expectedVersion makes the merge conditional on the survivor's version.
+func (r *Repository) Merge(ctx context.Context, survivorID, expectedVersion int64, req *model.MergeRequest) (*model.Person, error) {
+	var person *model.Person
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock in id order so that opposite merges of the same pair cannot
+		// deadlock.
+		var survivor, duplicate *model.Person
+		var err error
+		if survivorID < req.DuplicateID {
+			if survivor, err = lockPerson(ctx, tx, survivorID, expectedVersion, false); err != nil {
+				return err
+			}
+			if duplicate, err = lockPerson(ctx, tx, req.DuplicateID, 0, false); err != nil {
+				return err
+			}
+		} else {
+			if duplicate, err = lockPerson(ctx, tx, req.DuplicateID, 0, false); err != nil {
+				return err
+			}
+			if survivor, err = lockPerson(ctx, tx, survivorID, expectedVersion, false); err != nil {
+				return err
+			}
+		}
+
+		// The duplicate goes first: under the strict uniqueness policy the
+		// survivor may take over its natural key.
+		deleted, err := softDeletePerson(ctx, tx, duplicate.ID, duplicate.Version)
+		if err != nil {
+			return err
+		}
+		changes := model.DiffPersons(duplicate, deleted)
+		changes["merged_into"] = model.FieldChange{New: survivor.ID}
+		if err := insertAuditChanges(ctx, tx, model.AuditMerge, deleted, changes); err != nil {
+			return err
+		}
+
+		person, err = r.updatePerson(ctx, tx, model.MergePersons(survivor, duplicate, req), survivor.Version)
+		if err != nil {
+			return err
+		}
+		changes = model.DiffPersons(survivor, person)
+		changes["merged_from"] = model.FieldChange{New: duplicate.ID}
+		return insertAuditChanges(ctx, tx, model.AuditMerge, person, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return person, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// exportFetchSize is how many rows each FETCH pulls from the export cursor.
+const exportFetchSize = 1000
+
+// Export streams every person matching filters, in sort order, to fn. Rows are
+// read from a server-side cursor in batches of exportFetchSize, so memory use
+// does not grow with the size of the result. Iteration stops at the first
+// error returned by fn.
+func (r *Repository) Export(ctx context.Context, filters map[string]string, sort model.Sort, fn func(*model.Person) error) error {
+	conditions, args := filterConditions(filters)
+	query := "SELECT " + personColumns + " FROM persons"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + orderBy(sort)
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DECLARE persons_export NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM persons_export", exportFetchSize)
+	for {
+		n, err := fetchPersons(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+func fetchPersons(ctx context.Context, tx *sql.Tx, fetch string, fn func(*model.Person) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		person, err := scanPerson(rows)
+		if err != nil {
+			return n, err
+		}
+		if err := fn(person); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, rows.Err()
+}
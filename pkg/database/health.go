@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Ping checks that the database accepts connections.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// SchemaVersion returns the migration version recorded by golang-migrate
+// and whether that migration failed halfway. A database no migration ran on
+// yet is at version zero.
+func (r *Repository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	var version uint
+	var dirty bool
+	err := r.conn().QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return version, dirty, nil
+}
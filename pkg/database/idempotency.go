@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// ClaimIdempotencyKey takes the key for a request with the given body hash
+// under token, which the holder passes back to SaveIdempotentResponse and
+// ReleaseIdempotencyKey. It returns nil when the caller now holds the key and
+// must run the request,
+// or the stored response when the same request has already completed. Keys
+// that expired, or whose holder stopped before staleBefore without storing a
+// response, are taken over.
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, key, token, requestHash string, expiresAt, staleBefore time.Time) (*model.IdempotentResponse, error) {
+	claim := `
+        INSERT INTO idempotency_keys (key, request_hash, expires_at, token)
+        VALUES ($1, $2, $3, $5)
+        ON CONFLICT (key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash,
+            token = EXCLUDED.token,
+            status_code = NULL,
+            headers = NULL,
+            response = NULL,
+            created_at = CURRENT_TIMESTAMP,
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at < CURRENT_TIMESTAMP
+           OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $4)
+        RETURNING key`
+
+	var claimed string
+	err := r.conn().QueryRowContext(ctx, claim, key, requestHash, expiresAt, staleBefore, token).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	query := `
+        SELECT request_hash, status_code, headers, response
+        FROM idempotency_keys
+        WHERE key = $1`
+
+	var (
+		storedHash string
+		statusCode sql.NullInt64
+		headers    []byte
+		body       []byte
+	)
+	err = r.conn().QueryRowContext(ctx, query, key).Scan(&storedHash, &statusCode, &headers, &body)
+	if err == sql.ErrNoRows {
+		// The holder released the key between the two statements.
+		return nil, model.ErrIdempotencyKeyInFlight
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storedHash != requestHash {
+		return nil, model.ErrIdempotencyKeyReused
+	}
+	if !statusCode.Valid {
+		return nil, model.ErrIdempotencyKeyInFlight
+	}
+
+	resp := &model.IdempotentResponse{StatusCode: int(statusCode.Int64), Body: body}
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &resp.Headers); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// SaveIdempotentResponse stores the response of the request holding key with
+// token. It returns model.ErrIdempotencyClaimLost if the claim has been taken
+// over in the meantime.
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, key, token string, resp *model.IdempotentResponse) error {
+	headers, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE idempotency_keys
+        SET status_code = $2, headers = $3, response = $4
+        WHERE key = $1 AND token = $5 AND status_code IS NULL`
+
+	result, err := r.conn().ExecContext(ctx, query, key, resp.StatusCode, headers, resp.Body, token)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrIdempotencyClaimLost
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops the claim held with token if it has no response
+// stored, so the request can be retried with the same key.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, key, token string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND token = $2 AND status_code IS NULL`
+	_, err := r.conn().ExecContext(ctx, query, key, token)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes keys past their expiry.
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := r.conn().ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/lib/pq"
+)
+
+// CreateImportJob stores a new import job.
+func (r *Repository) CreateImportJob(ctx context.Context, job *model.ImportJob) error {
+	query := `
+        INSERT INTO import_jobs (id, status, dry_run, total_rows, created_at)
+        VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.conn().ExecContext(ctx, query, job.ID, job.Status, job.DryRun, job.TotalRows, job.CreatedAt)
+	return err
+}
+
+// UpdateImportJob stores the progress of job. Once the job has finished, its
+// row errors are stored along with it.
+func (r *Repository) UpdateImportJob(ctx context.Context, job *model.ImportJob) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		query := `
+            UPDATE import_jobs
+            SET status = $2, imported = $3, rejected = $4, error = NULLIF($5, ''),
+                finished_at = $6, updated_at = CURRENT_TIMESTAMP
+            WHERE id = $1`
+
+		result, err := tx.ExecContext(ctx, query, job.ID, job.Status, job.Imported, job.Rejected, job.Error, job.FinishedAt)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return model.ErrImportNotFound
+		}
+		if job.FinishedAt == nil || len(job.Errors) == 0 {
+			return nil
+		}
+
+		rows := make([]int64, len(job.Errors))
+		reasons := make([]string, len(job.Errors))
+		for i, rowErr := range job.Errors {
+			rows[i] = int64(rowErr.Row)
+			reasons[i] = rowErr.Reason
+		}
+		query = `
+            INSERT INTO import_job_errors (job_id, row_number, reason)
+            SELECT $1, e.row_number, e.reason
+            FROM unnest($2::integer[], $3::text[]) AS e(row_number, reason)
+            ON CONFLICT (job_id, row_number) DO NOTHING`
+
+		_, err = tx.ExecContext(ctx, query, job.ID, pq.Array(rows), pq.Array(reasons))
+		return err
+	})
+}
+
+// GetImportJob returns the import job with the given id, without its row
+// errors.
+func (r *Repository) GetImportJob(ctx context.Context, id string) (*model.ImportJob, error) {
+	query := `
+        SELECT id, status, dry_run, total_rows, imported, rejected, coalesce(error, ''), created_at, finished_at
+        FROM import_jobs
+        WHERE id = $1`
+
+	var (
+		job        model.ImportJob
+		finishedAt sql.NullTime
+	)
+	err := r.conn().QueryRowContext(ctx, query, id).Scan(&job.ID, &job.Status, &job.DryRun, &job.TotalRows,
+		&job.Imported, &job.Rejected, &job.Error, &job.CreatedAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		return nil, model.ErrImportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// ImportErrors returns the rejected rows of an import job in row order.
+func (r *Repository) ImportErrors(ctx context.Context, id string) ([]model.ImportRowError, error) {
+	query := `SELECT row_number, reason FROM import_job_errors WHERE job_id = $1 ORDER BY row_number`
+
+	rows, err := r.conn().QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errs []model.ImportRowError
+	for rows.Next() {
+		var rowErr model.ImportRowError
+		if err := rows.Scan(&rowErr.Row, &rowErr.Reason); err != nil {
+			return nil, err
+		}
+		errs = append(errs, rowErr)
+	}
+	return errs, rows.Err()
+}
+
+// DeleteImportJobs removes import jobs, with their row errors, that have not
+// changed since before.
+func (r *Repository) DeleteImportJobs(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.conn().ExecContext(ctx, `DELETE FROM import_jobs WHERE updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
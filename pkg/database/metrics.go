@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/metrics"
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/Mukam21/server_Golang/pkg/repository"
+)
+
+// instrumentedRepository times every method of the repository it wraps.
+type instrumentedRepository struct {
+	repo repository.Repository
+}
+
+// Instrument wraps repo so that each method call is recorded in
+// metrics.DBQueryDuration, including those made inside WithTx.
+func Instrument(repo repository.Repository) repository.Repository {
+	return &instrumentedRepository{repo: repo}
+}
+
+func observe(method string, start time.Time, err *error) {
+	metrics.DBQueryDuration.WithLabelValues(method, metrics.Outcome(*err)).Observe(time.Since(start).Seconds())
+}
+
+func (r *instrumentedRepository) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx repository.Repository) error) (err error) {
+	defer observe("WithTx", time.Now(), &err)
+	return r.repo.WithTx(ctx, opts, func(tx repository.Repository) error {
+		return fn(&instrumentedRepository{repo: tx})
+	})
+}
+
+func (r *instrumentedRepository) Create(ctx context.Context, person *model.Person) (_ int64, err error) {
+	defer observe("Create", time.Now(), &err)
+	return r.repo.Create(ctx, person)
+}
+
+func (r *instrumentedRepository) CreateBatch(ctx context.Context, persons []*model.Person) (err error) {
+	defer observe("CreateBatch", time.Now(), &err)
+	return r.repo.CreateBatch(ctx, persons)
+}
+
+func (r *instrumentedRepository) ExistingKeys(ctx context.Context, keys []string) (_ map[string]bool, err error) {
+	defer observe("ExistingKeys", time.Now(), &err)
+	return r.repo.ExistingKeys(ctx, keys)
+}
+
+func (r *instrumentedRepository) Export(ctx context.Context, filters map[string]string, sort model.Sort, fn func(*model.Person) error) (err error) {
+	defer observe("Export", time.Now(), &err)
+	return r.repo.Export(ctx, filters, sort, fn)
+}
+
+func (r *instrumentedRepository) GetByID(ctx context.Context, id int64, includeDeleted bool) (_ *model.Person, err error) {
+	defer observe("GetByID", time.Now(), &err)
+	return r.repo.GetByID(ctx, id, includeDeleted)
+}
+
+func (r *instrumentedRepository) GetByNaturalKey(ctx context.Context, key string) (_ *model.Person, err error) {
+	defer observe("GetByNaturalKey", time.Now(), &err)
+	return r.repo.GetByNaturalKey(ctx, key)
+}
+
+func (r *instrumentedRepository) GetAll(ctx context.Context, page, limit int, filters map[string]string, sort model.Sort) (_ []*model.Person, err error) {
+	defer observe("GetAll", time.Now(), &err)
+	return r.repo.GetAll(ctx, page, limit, filters, sort)
+}
+
+func (r *instrumentedRepository) Stats(ctx context.Context, filters map[string]string, ageBuckets []int) (_ *model.PersonStats, err error) {
+	defer observe("Stats", time.Now(), &err)
+	return r.repo.Stats(ctx, filters, ageBuckets)
+}
+
+func (r *instrumentedRepository) CountCreated(ctx context.Context, filters map[string]string, interval, timeZone, groupBy string, from, to time.Time) (_ []model.BucketCount, err error) {
+	defer observe("CountCreated", time.Now(), &err)
+	return r.repo.CountCreated(ctx, filters, interval, timeZone, groupBy, from, to)
+}
+
+func (r *instrumentedRepository) Update(ctx context.Context, person *model.Person, expectedVersion int64) (err error) {
+	defer observe("Update", time.Now(), &err)
+	return r.repo.Update(ctx, person, expectedVersion)
+}
+
+func (r *instrumentedRepository) Upsert(ctx context.Context, person *model.Person, expectedVersion int64) (_ bool, err error) {
+	defer observe("Upsert", time.Now(), &err)
+	return r.repo.Upsert(ctx, person, expectedVersion)
+}
+
+func (r *instrumentedRepository) Patch(ctx context.Context, id, expectedVersion int64, patch *model.PersonPatchRequest) (_ *model.Person, err error) {
+	defer observe("Patch", time.Now(), &err)
+	return r.repo.Patch(ctx, id, expectedVersion, patch)
+}
+
+func (r *instrumentedRepository) Delete(ctx context.Context, id, expectedVersion int64) (err error) {
+	defer observe("Delete", time.Now(), &err)
+	return r.repo.Delete(ctx, id, expectedVersion)
+}
+
+func (r *instrumentedRepository) Restore(ctx context.Context, id, expectedVersion int64) (_ *model.Person, err error) {
+	defer observe("Restore", time.Now(), &err)
+	return r.repo.Restore(ctx, id, expectedVersion)
+}
+
+func (r *instrumentedRepository) Purge(ctx context.Context, deletedBefore time.Time) (_ int64, err error) {
+	defer observe("Purge", time.Now(), &err)
+	return r.repo.Purge(ctx, deletedBefore)
+}
+
+func (r *instrumentedRepository) History(ctx context.Context, personID int64, page, limit int) (_ []*model.AuditEntry, err error) {
+	defer observe("History", time.Now(), &err)
+	return r.repo.History(ctx, personID, page, limit)
+}
+
+func (r *instrumentedRepository) GetAsOf(ctx context.Context, id int64, asOf time.Time) (_ *model.Person, err error) {
+	defer observe("GetAsOf", time.Now(), &err)
+	return r.repo.GetAsOf(ctx, id, asOf)
+}
+
+func (r *instrumentedRepository) Revert(ctx context.Context, id, version, expectedVersion int64) (_ *model.Person, err error) {
+	defer observe("Revert", time.Now(), &err)
+	return r.repo.Revert(ctx, id, version, expectedVersion)
+}
+
+func (r *instrumentedRepository) DuplicateCandidates(ctx context.Context, personID int64, neighbors, limit int) (_ [][2]*model.Person, _ bool, err error) {
+	defer observe("DuplicateCandidates", time.Now(), &err)
+	return r.repo.DuplicateCandidates(ctx, personID, neighbors, limit)
+}
+
+func (r *instrumentedRepository) Merge(ctx context.Context, survivorID, expectedVersion int64, req *model.MergeRequest) (_ *model.Person, err error) {
+	defer observe("Merge", time.Now(), &err)
+	return r.repo.Merge(ctx, survivorID, expectedVersion, req)
+}
+
+func (r *instrumentedRepository) ClaimIdempotencyKey(ctx context.Context, key, token, requestHash string, expiresAt, staleBefore time.Time) (_ *model.IdempotentResponse, err error) {
+	defer observe("ClaimIdempotencyKey", time.Now(), &err)
+	return r.repo.ClaimIdempotencyKey(ctx, key, token, requestHash, expiresAt, staleBefore)
+}
+
+func (r *instrumentedRepository) SaveIdempotentResponse(ctx context.Context, key, token string, resp *model.IdempotentResponse) (err error) {
+	defer observe("SaveIdempotentResponse", time.Now(), &err)
+	return r.repo.SaveIdempotentResponse(ctx, key, token, resp)
+}
+
+func (r *instrumentedRepository) ReleaseIdempotencyKey(ctx context.Context, key, token string) (err error) {
+	defer observe("ReleaseIdempotencyKey", time.Now(), &err)
+	return r.repo.ReleaseIdempotencyKey(ctx, key, token)
+}
+
+func (r *instrumentedRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) (_ int64, err error) {
+	defer observe("DeleteExpiredIdempotencyKeys", time.Now(), &err)
+	return r.repo.DeleteExpiredIdempotencyKeys(ctx)
+}
+
+func (r *instrumentedRepository) CreateImportJob(ctx context.Context, job *model.ImportJob) (err error) {
+	defer observe("CreateImportJob", time.Now(), &err)
+	return r.repo.CreateImportJob(ctx, job)
+}
+
+func (r *instrumentedRepository) UpdateImportJob(ctx context.Context, job *model.ImportJob) (err error) {
+	defer observe("UpdateImportJob", time.Now(), &err)
+	return r.repo.UpdateImportJob(ctx, job)
+}
+
+func (r *instrumentedRepository) GetImportJob(ctx context.Context, id string) (_ *model.ImportJob, err error) {
+	defer observe("GetImportJob", time.Now(), &err)
+	return r.repo.GetImportJob(ctx, id)
+}
+
+func (r *instrumentedRepository) ImportErrors(ctx context.Context, id string) (_ []model.ImportRowError, err error) {
+	defer observe("ImportErrors", time.Now(), &err)
+	return r.repo.ImportErrors(ctx, id)
+}
+
+func (r *instrumentedRepository) DeleteImportJobs(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer observe("DeleteImportJobs", time.Now(), &err)
+	return r.repo.DeleteImportJobs(ctx, before)
+}
+
+func (r *instrumentedRepository) Ping(ctx context.Context) (err error) {
+	defer observe("Ping", time.Now(), &err)
+	return r.repo.Ping(ctx)
+}
+
+func (r *instrumentedRepository) SchemaVersion(ctx context.Context) (_ uint, _ bool, err error) {
+	defer observe("SchemaVersion", time.Now(), &err)
+	return r.repo.SchemaVersion(ctx)
+}
@@ -1,28 +1,162 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
+	"time"
 
-	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/migrations"
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/sirupsen/logrus"
 )
 
-func RunMigrations(cfg *config.Config, log *logrus.Logger) error {
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+// Migrator applies the migrations embedded in the migrations package. Every
+// operation holds a PostgreSQL advisory lock, so replicas starting together
+// apply each migration once while the others wait for them.
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	conn   *sql.Conn
+}
+
+// MigrationStatus describes the schema version of the database.
+type MigrationStatus struct {
+	// Version is the last applied migration, zero if none is.
+	Version uint
+	// Dirty is set when that migration failed halfway and needs Force.
+	Dirty bool
+	// Pending are the embedded migrations newer than Version.
+	Pending []uint
+}
+
+// NewMigrator prepares migrations over a connection taken from db. Waiting
+// for another replica's migrations gives up after lockTimeout.
+func NewMigrator(ctx context.Context, db *sql.DB, lockTimeout time.Duration, log *logrus.Logger) (*Migrator, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	// The driver closes what it is given, so it gets a connection of its
+	// own rather than the pool.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// DB_STATEMENT_TIMEOUT is meant for requests; index builds may take
+	// longer.
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to init migrations: %v", err)
+	}
 
-	m, err := migrate.New("file://migrations", dsn)
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
 	if err != nil {
-		return fmt.Errorf("failed to init migrations: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("failed to init migrations: %v", err)
 	}
+	m.LockTimeout = lockTimeout
+	m.Log = migrateLogger{log}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+	return &Migrator{m: m, source: src, conn: conn}, nil
+}
+
+// Close releases the migrator's connection back to the pool.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down rolls back the last steps migrations.
+func (m *Migrator) Down(steps int) error {
+	return ignoreNoChange(m.m.Steps(-steps))
+}
+
+// Goto migrates up or down to version.
+func (m *Migrator) Goto(version uint) error {
+	return ignoreNoChange(m.m.Migrate(version))
+}
+
+// Force records version as applied and clears the dirty flag without
+// running anything, for recovering from a failed migration by hand.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+func (m *Migrator) Status() (*MigrationStatus, error) {
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	status := &MigrationStatus{Version: version, Dirty: dirty}
+
+	next, err := m.source.First()
+	for err == nil {
+		if next > version {
+			status.Pending = append(status.Pending, next)
+		}
+		next, err = m.source.Next(next)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// RunMigrations applies all pending migrations to db.
+func RunMigrations(ctx context.Context, db *sql.DB, lockTimeout time.Duration, log *logrus.Logger) error {
+	m, err := NewMigrator(ctx, db, lockTimeout, log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil {
 		return fmt.Errorf("failed to apply migrations: %v", err)
 	}
 
-	log.Info("Migrations applied successfully")
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	log.Infof("Database schema is at version %d", status.Version)
 	return nil
 }
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+// migrateLogger passes golang-migrate's progress messages to logrus.
+type migrateLogger struct {
+	log *logrus.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...interface{}) {
+	l.log.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
@@ -1,77 +1,222 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Mukam21/server_Golang/pkg/config"
 	"github.com/Mukam21/server_Golang/pkg/model"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
 )
 
+const personColumns = "id, name, surname, patronymic, age, gender, nationality, created_at, updated_at, version, deleted_at"
+
 type Repository struct {
-	db *sql.DB
+	db  *sql.DB
+	log *logrus.Logger
+	// tx is set on the repositories WithTx hands out; their methods run in it.
+	tx *sql.Tx
+	// strictKeys fills in natural_key, whose unique index then rejects a
+	// second live person with the same name.
+	strictKeys bool
+	isolation  sql.IsolationLevel
+	maxRetries int
 }
 
-func NewPostgresDB(cfg *config.Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
+func scanPerson(row rowScanner) (*model.Person, error) {
+	person := &model.Person{}
+	if err := row.Scan(personFields(person)...); err != nil {
 		return nil, err
 	}
+	return person, nil
+}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+// personFields returns scan destinations for personColumns.
+func personFields(person *model.Person) []interface{} {
+	return []interface{}{
+		&person.ID,
+		&person.Name,
+		&person.Surname,
+		&person.Patronymic,
+		&person.Age,
+		&person.Gender,
+		&person.Nationality,
+		&person.CreatedAt,
+		&person.UpdatedAt,
+		&person.Version,
+		&person.DeletedAt,
 	}
+}
 
-	return db, nil
+func NewRepository(db *sql.DB, cfg *config.Config, log *logrus.Logger) *Repository {
+	return &Repository{
+		db:         db,
+		log:        log,
+		strictKeys: cfg.UniquenessPolicy == config.UniquenessStrict,
+		isolation:  isolationLevels[cfg.DBTxIsolation],
+		maxRetries: cfg.DBTxMaxRetries,
+	}
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+// keys returns the name_key and natural_key stored for person: its natural
+// key, and the same again under the strict uniqueness policy or NULL
+// otherwise.
+func (r *Repository) keys(person *model.Person) (string, *string) {
+	key := model.NaturalKey(person.Name, person.Surname, person.Patronymic)
+	if !r.strictKeys {
+		return key, nil
+	}
+	return key, &key
 }
 
-func (r *Repository) Create(person *model.Person) (int64, error) {
+func (r *Repository) Create(ctx context.Context, person *model.Person) (int64, error) {
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		return r.insertPerson(ctx, tx, person)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return person.ID, nil
+}
+
+// insertPerson inserts person and its create audit entries, filling in its
+// id and timestamps.
+func (r *Repository) insertPerson(ctx context.Context, tx *sql.Tx, person *model.Person) error {
 	query := `
-        INSERT INTO persons (name, surname, patronymic, age, gender, nationality)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING id`
+        INSERT INTO persons (name, surname, patronymic, age, gender, nationality, name_key, natural_key)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at, updated_at, version`
 
-	var id int64
-	err := r.db.QueryRow(query,
+	nameKey, naturalKey := r.keys(person)
+	err := tx.QueryRowContext(ctx, query,
 		person.Name,
 		person.Surname,
 		person.Patronymic,
 		person.Age,
 		person.Gender,
 		person.Nationality,
-	).Scan(&id)
+		nameKey,
+		naturalKey,
+	).Scan(&person.ID, &person.CreatedAt, &person.UpdatedAt, &person.Version)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	return insertCreateAudit(ctx, tx, person)
+}
 
-	return id, nil
+// batchInsertSize keeps multi-row inserts well below PostgreSQL's limit of
+// 65535 bind parameters per statement.
+const batchInsertSize = 1000
+
+// CreateBatch inserts all persons in a single transaction using multi-row
+// INSERTs, filling in their ids and timestamps.
+func (r *Repository) CreateBatch(ctx context.Context, persons []*model.Person) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		for start := 0; start < len(persons); start += batchInsertSize {
+			end := start + batchInsertSize
+			if end > len(persons) {
+				end = len(persons)
+			}
+			if err := r.insertPersons(ctx, tx, persons[start:end]); err != nil {
+				return err
+			}
+		}
+
+		for _, person := range persons {
+			if err := insertCreateAudit(ctx, tx, person); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (r *Repository) GetByID(id int64) (*model.Person, error) {
-	query := `
-        SELECT id, name, surname, patronymic, age, gender, nationality
-        FROM persons
-        WHERE id = $1`
+func (r *Repository) insertPersons(ctx context.Context, tx *sql.Tx, persons []*model.Person) error {
+	values := make([]string, 0, len(persons))
+	args := make([]interface{}, 0, len(persons)*8)
+	for i, person := range persons {
+		n := i * 8
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+		nameKey, naturalKey := r.keys(person)
+		args = append(args,
+			person.Name,
+			person.Surname,
+			person.Patronymic,
+			person.Age,
+			person.Gender,
+			person.Nationality,
+			nameKey,
+			naturalKey,
+		)
+	}
 
-	person := &model.Person{}
-	err := r.db.QueryRow(query, id).Scan(
-		&person.ID,
-		&person.Name,
-		&person.Surname,
-		&person.Patronymic,
-		&person.Age,
-		&person.Gender,
-		&person.Nationality,
-	)
+	// PostgreSQL returns the rows of a multi-row VALUES insert in input order.
+	query := "INSERT INTO persons (name, surname, patronymic, age, gender, nationality, name_key, natural_key) VALUES " +
+		strings.Join(values, ", ") + " RETURNING id, created_at, updated_at, version"
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	i := 0
+	for rows.Next() {
+		person := persons[i]
+		if err := rows.Scan(&person.ID, &person.CreatedAt, &person.UpdatedAt, &person.Version); err != nil {
+			return err
+		}
+		i++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if i != len(persons) {
+		return fmt.Errorf("inserted %d of %d persons", i, len(persons))
+	}
+
+	return nil
+}
+
+// insertCreateAudit records a new person as a create entry for the
+// client-supplied fields followed by an enrich entry for the looked-up ones.
+func insertCreateAudit(ctx context.Context, tx *sql.Tx, person *model.Person) error {
+	created := make(model.FieldChanges)
+	enriched := make(model.FieldChanges)
+	for field, change := range model.DiffPersons(nil, person) {
+		if model.EnrichedFields[field] {
+			enriched[field] = change
+		} else {
+			created[field] = change
+		}
+	}
+
+	if err := insertAuditChanges(ctx, tx, model.AuditCreate, person, created); err != nil {
+		return err
+	}
+	if len(enriched) == 0 {
+		return nil
+	}
+	return insertAuditChanges(ctx, tx, model.AuditEnrich, person, enriched)
+}
+
+func (r *Repository) GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.Person, error) {
+	query := "SELECT " + personColumns + " FROM persons WHERE id = $1"
+	if !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+
+	person, err := scanPerson(r.conn().QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -82,12 +227,45 @@ func (r *Repository) GetByID(id int64) (*model.Person, error) {
 	return person, nil
 }
 
-func (r *Repository) GetAll(page, limit int, filters map[string]string) ([]*model.Person, error) {
+func (r *Repository) GetAll(ctx context.Context, page, limit int, filters map[string]string, sort model.Sort) ([]*model.Person, error) {
 	offset := (page - 1) * limit
+	conditions, args := filterConditions(filters)
+	argIndex := len(args) + 1
+
+	query := "SELECT " + personColumns + " FROM persons"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + orderBy(sort)
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+	args = append(args, limit, offset)
+
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var persons []*model.Person
+	for rows.Next() {
+		person, err := scanPerson(rows)
+		if err != nil {
+			return nil, err
+		}
+		persons = append(persons, person)
+	}
+
+	return persons, rows.Err()
+}
+
+func filterConditions(filters map[string]string) ([]string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 	argIndex := 1
 
+	if filters["include_deleted"] != "true" {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
 	if name := filters["name"]; name != "" {
 		conditions = append(conditions, fmt.Sprintf("name ILIKE $%d", argIndex))
 		args = append(args, "%"+name+"%")
@@ -113,72 +291,90 @@ func (r *Repository) GetAll(page, limit int, filters map[string]string) ([]*mode
 		args = append(args, nationality)
 		argIndex++
 	}
-
-	query := "SELECT id, name, surname, patronymic, age, gender, nationality FROM persons"
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	if createdSince := filters["created_since"]; createdSince != "" {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIndex))
+		args = append(args, createdSince)
+		argIndex++
+	}
+	if createdBefore := filters["created_before"]; createdBefore != "" {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argIndex))
+		args = append(args, createdBefore)
+		argIndex++
+	}
+	if updatedSince := filters["updated_since"]; updatedSince != "" {
+		conditions = append(conditions, fmt.Sprintf("updated_at >= $%d", argIndex))
+		args = append(args, updatedSince)
+		argIndex++
+	}
+	if updatedBefore := filters["updated_before"]; updatedBefore != "" {
+		conditions = append(conditions, fmt.Sprintf("updated_at < $%d", argIndex))
+		args = append(args, updatedBefore)
 	}
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
-	args = append(args, limit, offset)
 
-	rows, err := r.db.Query(query, args...)
-	if err != nil {
-		return nil, err
+	return conditions, args
+}
+
+// orderBy builds the ORDER BY clause for sort, always breaking ties by id so
+// that paging over equal timestamps stays stable.
+func orderBy(sort model.Sort) string {
+	if !model.SortFields[sort.Field] {
+		sort.Field = "id"
 	}
-	defer rows.Close()
+	direction := "ASC"
+	if sort.Desc {
+		direction = "DESC"
+	}
+	if sort.Field == "id" {
+		return "id " + direction
+	}
+	return fmt.Sprintf("%s %s, id %s", sort.Field, direction, direction)
+}
 
-	var persons []*model.Person
-	for rows.Next() {
-		person := &model.Person{}
-		err := rows.Scan(
-			&person.ID,
-			&person.Name,
-			&person.Surname,
-			&person.Patronymic,
-			&person.Age,
-			&person.Gender,
-			&person.Nationality,
-		)
+// Update replaces the person's fields. A non-zero expectedVersion makes the
+// write conditional on the stored version, which is incremented on success.
+func (r *Repository) Update(ctx context.Context, person *model.Person, expectedVersion int64) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockPerson(ctx, tx, person.ID, expectedVersion, false)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		persons = append(persons, person)
-	}
 
-	return persons, nil
+		after, err := r.updatePerson(ctx, tx, person, before.Version)
+		if err != nil {
+			return err
+		}
+		*person = *after
+
+		return insertAudit(ctx, tx, model.AuditUpdate, before, after)
+	})
 }
 
-func (r *Repository) Update(person *model.Person) error {
+// updatePerson writes all of person's fields over the stored row at version
+// and returns the new state.
+func (r *Repository) updatePerson(ctx context.Context, tx *sql.Tx, person *model.Person, version int64) (*model.Person, error) {
 	query := `
         UPDATE persons
-        SET name = $1, surname = $2, patronymic = $3, age = $4, gender = $5, nationality = $6
-        WHERE id = $7`
+        SET name = $1, surname = $2, patronymic = $3, age = $4, gender = $5, nationality = $6,
+            name_key = $7, natural_key = $8, version = version + 1
+        WHERE id = $9 AND version = $10
+        RETURNING ` + personColumns
 
-	result, err := r.db.Exec(query,
+	nameKey, naturalKey := r.keys(person)
+	return scanPerson(tx.QueryRowContext(ctx, query,
 		person.Name,
 		person.Surname,
 		person.Patronymic,
 		person.Age,
 		person.Gender,
 		person.Nationality,
+		nameKey,
+		naturalKey,
 		person.ID,
-	)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
-	return nil
+		version,
+	))
 }
 
-func (r *Repository) Patch(id int64, patch *model.PersonPatchRequest) error {
+func (r *Repository) Patch(ctx context.Context, id, expectedVersion int64, patch *model.PersonPatchRequest) (*model.Person, error) {
 	var updates []string
 	var args []interface{}
 	argIndex := 1
@@ -215,43 +411,211 @@ func (r *Repository) Patch(id int64, patch *model.PersonPatchRequest) error {
 	}
 
 	if len(updates) == 0 {
-		return fmt.Errorf("no fields to update")
+		return nil, fmt.Errorf("no fields to update")
 	}
+	updates = append(updates, "version = version + 1",
+		fmt.Sprintf("name_key = $%d", argIndex), fmt.Sprintf("natural_key = $%d", argIndex+1))
 
-	query := fmt.Sprintf("UPDATE persons SET %s WHERE id = $%d",
-		strings.Join(updates, ", "), argIndex)
-	args = append(args, id)
+	query := fmt.Sprintf("UPDATE persons SET %s WHERE id = $%d AND version = $%d RETURNING %s",
+		strings.Join(updates, ", "), argIndex+2, argIndex+3, personColumns)
 
-	result, err := r.db.Exec(query, args...)
+	var person *model.Person
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockPerson(ctx, tx, id, expectedVersion, false)
+		if err != nil {
+			return err
+		}
+
+		patched := *before
+		if patch.Name != nil {
+			patched.Name = *patch.Name
+		}
+		if patch.Surname != nil {
+			patched.Surname = *patch.Surname
+		}
+		if patch.Patronymic != nil {
+			patched.Patronymic = patch.Patronymic
+		}
+
+		nameKey, naturalKey := r.keys(&patched)
+		person, err = scanPerson(tx.QueryRowContext(ctx, query,
+			append(args, nameKey, naturalKey, id, before.Version)...))
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, model.AuditPatch, before, person)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return person, nil
+}
+
+// Delete soft-deletes the person by stamping deleted_at. The row stays in the
+// table until Purge removes it.
+func (r *Repository) Delete(ctx context.Context, id, expectedVersion int64) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockPerson(ctx, tx, id, expectedVersion, false)
+		if err != nil {
+			return err
+		}
+
+		after, err := softDeletePerson(ctx, tx, id, before.Version)
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, model.AuditDelete, before, after)
+	})
+}
+
+func softDeletePerson(ctx context.Context, tx *sql.Tx, id, version int64) (*model.Person, error) {
+	query := `
+        UPDATE persons
+        SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id = $1 AND version = $2
+        RETURNING ` + personColumns
+
+	return scanPerson(tx.QueryRowContext(ctx, query, id, version))
+}
+
+func (r *Repository) Restore(ctx context.Context, id, expectedVersion int64) (*model.Person, error) {
+	query := `
+        UPDATE persons
+        SET deleted_at = NULL, name_key = $3, natural_key = $4, version = version + 1
+        WHERE id = $1 AND version = $2
+        RETURNING ` + personColumns
+
+	var person *model.Person
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockPerson(ctx, tx, id, expectedVersion, true)
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt == nil {
+			return model.ErrNotDeleted
+		}
+
+		nameKey, naturalKey := r.keys(before)
+		person, err = scanPerson(tx.QueryRowContext(ctx, query, id, before.Version, nameKey, naturalKey))
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, model.AuditRestore, before, person)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+
+	return person, nil
+}
+
+// Purge permanently removes persons that were soft-deleted before the cutoff.
+// Their audit history is kept.
+func (r *Repository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := "DELETE FROM persons WHERE deleted_at < $1 RETURNING " + personColumns
+
+	var purged int64
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, deletedBefore)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var persons []*model.Person
+		for rows.Next() {
+			person, err := scanPerson(rows)
+			if err != nil {
+				return err
+			}
+			persons = append(persons, person)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, person := range persons {
+			if err := insertAudit(ctx, tx, model.AuditPurge, person, nil); err != nil {
+				return err
+			}
+		}
+		purged = int64(len(persons))
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return purged, nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM persons WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// lockPerson loads the person for update within tx and checks it against
+// expectedVersion, where zero accepts any version. Soft-deleted persons are
+// reported as not found unless includeDeleted is set.
+func lockPerson(ctx context.Context, tx *sql.Tx, id, expectedVersion int64, includeDeleted bool) (*model.Person, error) {
+	query := "SELECT " + personColumns + " FROM persons WHERE id = $1 FOR UPDATE"
+
+	person, err := scanPerson(tx.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, model.ErrNotFound
+	}
 	if err != nil {
+		return nil, err
+	}
+	if person.DeletedAt != nil && !includeDeleted {
+		return nil, model.ErrNotFound
+	}
+	if expectedVersion != 0 && person.Version != expectedVersion {
+		return nil, model.ErrVersionMismatch
+	}
+
+	return person, nil
+}
+
+// duplicateError turns a violation of the natural key index into a
+// model.DuplicatePersonError naming the person already holding the key.
+// Other errors are returned unchanged.
+func (r *Repository) duplicateError(ctx context.Context, err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" || pqErr.Constraint != "idx_persons_natural_key" {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	duplicate := &model.DuplicatePersonError{}
+	// The detail reads: Key (natural_key)=(<key>) already exists.
+	detail := strings.TrimPrefix(pqErr.Detail, "Key (natural_key)=(")
+	if end := strings.LastIndex(detail, ") already exists"); end >= 0 {
+		query := "SELECT id FROM persons WHERE natural_key = $1 AND deleted_at IS NULL"
+		if err := r.db.QueryRowContext(ctx, query, detail[:end]).Scan(&duplicate.ExistingID); err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return duplicate
+}
+
+// ExistingKeys returns which of the given natural keys (see model.NaturalKey)
+// already belong to a person that is not deleted.
+func (r *Repository) ExistingKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	query := "SELECT DISTINCT name_key FROM persons WHERE deleted_at IS NULL AND name_key = ANY ($1)"
+
+	rows, err := r.conn().QueryContext(ctx, query, pq.Array(keys))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		existing[key] = true
 	}
 
-	return nil
+	return existing, rows.Err()
 }
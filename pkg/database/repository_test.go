@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// testRepository returns a repository on an emptied, fully migrated
+// database named by TEST_DATABASE_URL, or skips the test when it is not set.
+func testRepository(t *testing.T, policy string) *Repository {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	ctx := context.Background()
+	if err := RunMigrations(ctx, db, time.Minute, log); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "TRUNCATE persons, person_audit, idempotency_keys, import_jobs, import_job_errors RESTART IDENTITY"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{UniquenessPolicy: policy, DBTxIsolation: "read_committed", DBTxMaxRetries: 3}
+	return NewRepository(db, cfg, log)
+}
+
+func createPerson(t *testing.T, repo *Repository, name, surname string) *model.Person {
+	t.Helper()
+	person := &model.Person{Name: name, Surname: surname}
+	if _, err := repo.Create(context.Background(), person); err != nil {
+		t.Fatal(err)
+	}
+	return person
+}
+
+func TestMergeTakesDuplicateNameUnderStrictPolicy(t *testing.T) {
+	repo := testRepository(t, config.UniquenessStrict)
+	ctx := context.Background()
+	duplicateSource := model.MergeFromDuplicate
+
+	tests := []struct {
+		name     string
+		surname  string
+		strategy model.MergeStrategy
+		fields   model.MergeFields
+	}{
+		{"prefer_duplicate", "Petrov", model.MergePreferDuplicate, model.MergeFields{}},
+		{"pinned surname", "Sidorov", model.MergeFillMissing, model.MergeFields{Surname: &duplicateSource}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			survivor := createPerson(t, repo, "Ivan", tt.surname)
+			duplicate := createPerson(t, repo, "Ivan", tt.surname+"ff")
+
+			req := &model.MergeRequest{DuplicateID: duplicate.ID, Strategy: tt.strategy, Fields: tt.fields}
+			merged, err := repo.Merge(ctx, survivor.ID, 0, req)
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			if merged.Surname != duplicate.Surname {
+				t.Errorf("surname = %q, want %q", merged.Surname, duplicate.Surname)
+			}
+
+			gone, err := repo.GetByID(ctx, duplicate.ID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gone.DeletedAt == nil {
+				t.Error("duplicate is not deleted")
+			}
+
+			found, err := repo.GetByNaturalKey(ctx, model.NaturalKey("Ivan", duplicate.Surname, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found == nil || found.ID != survivor.ID {
+				t.Errorf("natural key belongs to %v, want person %d", found, survivor.ID)
+			}
+		})
+	}
+}
+
+func TestBackfillNaturalKeys(t *testing.T) {
+	ctx := context.Background()
+	loose := testRepository(t, config.UniquenessNone)
+	first := createPerson(t, loose, "Anna", "Smirnova")
+	second := createPerson(t, loose, " anna ", "SMIRNOVA")
+	if _, err := loose.db.ExecContext(ctx, "UPDATE persons SET name_key = NULL"); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := NewRepository(loose.db, &config.Config{UniquenessPolicy: config.UniquenessStrict}, loose.log)
+	collisions, err := strict.BackfillNaturalKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collisions) != 1 || collisions[0] != second.ID {
+		t.Errorf("collisions = %v, want [%d]", collisions, second.ID)
+	}
+
+	found, err := strict.GetByNaturalKey(ctx, model.NaturalKey("Anna", "Smirnova", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != first.ID {
+		t.Errorf("natural key belongs to %v, want person %d", found, first.ID)
+	}
+	if _, err := strict.Create(ctx, &model.Person{Name: "ANNA", Surname: "smirnova"}); err == nil {
+		t.Error("strict policy accepted a third Anna Smirnova")
+	}
+}
+
+func TestRevertToDeletedVersionDeletesAnew(t *testing.T) {
+	repo := testRepository(t, config.UniquenessNone)
+	ctx := context.Background()
+	person := createPerson(t, repo, "Olga", "Ivanova")
+
+	if err := repo.Delete(ctx, person.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := repo.GetByID(ctx, person.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Restore(ctx, person.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := repo.Revert(ctx, person.ID, deleted.Version, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.DeletedAt == nil {
+		t.Fatal("reverted person is not deleted")
+	}
+	if !reverted.DeletedAt.After(*deleted.DeletedAt) {
+		t.Errorf("deleted_at = %v, want later than the original %v", reverted.DeletedAt, deleted.DeletedAt)
+	}
+}
+
+func TestIdempotencyClaimTakenOver(t *testing.T) {
+	repo := testRepository(t, config.UniquenessNone)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	// Claims made before staleBefore are taken over.
+	staleBefore := time.Now().Add(time.Minute)
+	hash := strings.Repeat("a", 64)
+
+	if _, err := repo.ClaimIdempotencyKey(ctx, "key", "first", hash, expiresAt, staleBefore); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ClaimIdempotencyKey(ctx, "key", "second", hash, expiresAt, staleBefore); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &model.IdempotentResponse{StatusCode: 201, Body: []byte("{}")}
+	if err := repo.SaveIdempotentResponse(ctx, "key", "first", resp); !errors.Is(err, model.ErrIdempotencyClaimLost) {
+		t.Fatalf("save with the stale token: err = %v, want %v", err, model.ErrIdempotencyClaimLost)
+	}
+	if err := repo.ReleaseIdempotencyKey(ctx, "key", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveIdempotentResponse(ctx, "key", "second", resp); err != nil {
+		t.Fatalf("save with the current token: %v", err)
+	}
+}
+
+func TestImportJobRoundTrip(t *testing.T) {
+	repo := testRepository(t, config.UniquenessNone)
+	ctx := context.Background()
+	job := &model.ImportJob{
+		ID:        strings.Repeat("1", 32),
+		Status:    model.ImportPending,
+		TotalRows: 3,
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+	}
+	if err := repo.CreateImportJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := time.Now().Truncate(time.Microsecond)
+	job.Status = model.ImportCompleted
+	job.Imported = 1
+	job.Rejected = 2
+	job.Errors = []model.ImportRowError{{Row: 4, Reason: "invalid"}, {Row: 2, Reason: "duplicate of row 3"}}
+	job.FinishedAt = &finished
+	if err := repo.UpdateImportJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetImportJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != model.ImportCompleted || got.Imported != 1 || got.Rejected != 2 ||
+		got.FinishedAt == nil || !got.FinishedAt.Equal(finished) {
+		t.Errorf("GetImportJob = %+v", got)
+	}
+	errs, err := repo.ImportErrors(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 || errs[0].Row != 2 || errs[1].Row != 4 {
+		t.Errorf("ImportErrors = %+v, want rows 2 and 4", errs)
+	}
+
+	if _, err := repo.DeleteImportJobs(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetImportJob(ctx, job.ID); !errors.Is(err, model.ErrImportNotFound) {
+		t.Errorf("GetImportJob after delete: err = %v, want %v", err, model.ErrImportNotFound)
+	}
+}
+
+func TestDuplicateCandidates(t *testing.T) {
+	repo := testRepository(t, config.UniquenessNone)
+	ctx := context.Background()
+	ivanov := createPerson(t, repo, "Ivan", "Ivanov")
+	ivanova := createPerson(t, repo, "Maria", "Ivanova")
+	createPerson(t, repo, "Oleg", "Sidorov")
+
+	pairs, truncated, err := repo.DuplicateCandidates(ctx, 0, 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || len(pairs) != 1 || pairs[0][0].ID != ivanov.ID || pairs[0][1].ID != ivanova.ID {
+		t.Fatalf("DuplicateCandidates = %v pairs, truncated %v; want the Ivanov pair once", len(pairs), truncated)
+	}
+
+	pairs, truncated, err = repo.DuplicateCandidates(ctx, ivanova.ID, 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || len(pairs) != 1 || pairs[0][0].ID != ivanov.ID {
+		t.Errorf("DuplicateCandidates of one person = %v pairs, truncated %v; want the Ivanov pair", len(pairs), truncated)
+	}
+
+	if _, truncated, err = repo.DuplicateCandidates(ctx, 0, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !truncated {
+		t.Error("scan capped at one candidate not reported as truncated")
+	}
+}
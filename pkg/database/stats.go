@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/lib/pq"
+)
+
+// Stats aggregates the persons matching filters: counts by gender and
+// nationality, an age histogram over the ascending edges in ageBuckets, age
+// summary figures and enrichment coverage. All figures come from one
+// snapshot of the table.
+func (r *Repository) Stats(ctx context.Context, filters map[string]string, ageBuckets []int) (*model.PersonStats, error) {
+	conditions, args := filterConditions(filters)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var stats *model.PersonStats
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := r.runTx(ctx, opts, func(tx *sql.Tx) error {
+		stats = &model.PersonStats{}
+
+		summary := `
+            SELECT count(*), count(age), count(gender), count(nationality),
+                   avg(age)::float8, percentile_cont(0.5) WITHIN GROUP (ORDER BY age), min(age), max(age)
+            FROM persons` + where
+
+		var withAge, withGender, withNationality int64
+		err := tx.QueryRowContext(ctx, summary, args...).Scan(
+			&stats.Total,
+			&withAge,
+			&withGender,
+			&withNationality,
+			&stats.Age.Mean,
+			&stats.Age.Median,
+			&stats.Age.Min,
+			&stats.Age.Max,
+		)
+		if err != nil {
+			return err
+		}
+		if stats.Age.Mean != nil {
+			mean := math.Round(*stats.Age.Mean*100) / 100
+			stats.Age.Mean = &mean
+		}
+		stats.Coverage = model.EnrichmentCoverage{
+			Age:         percent(withAge, stats.Total),
+			Gender:      percent(withGender, stats.Total),
+			Nationality: percent(withNationality, stats.Total),
+		}
+
+		if stats.ByGender, err = countByValue(ctx, tx, "gender", where, args); err != nil {
+			return err
+		}
+		if stats.ByNationality, err = countByValue(ctx, tx, "nationality", where, args); err != nil {
+			return err
+		}
+		stats.AgeHistogram, err = ageHistogram(ctx, tx, where, args, ageBuckets)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// countByValue counts persons per value of column, most common first.
+func countByValue(ctx context.Context, tx *sql.Tx, column, where string, args []interface{}) ([]model.ValueCount, error) {
+	query := fmt.Sprintf("SELECT %[1]s, count(*) FROM persons%[2]s GROUP BY %[1]s ORDER BY count(*) DESC, %[1]s", column, where)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]model.ValueCount, 0)
+	for rows.Next() {
+		var count model.ValueCount
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// ageHistogram counts persons with a known age per bucket. width_bucket puts
+// ages below the first edge in bucket 0 and ages from the last edge on in
+// bucket len(edges); every bucket is reported, including empty ones.
+func ageHistogram(ctx context.Context, tx *sql.Tx, where string, args []interface{}, edges []int) ([]model.AgeBucket, error) {
+	condition := " WHERE age IS NOT NULL"
+	if where != "" {
+		condition = where + " AND age IS NOT NULL"
+	}
+	query := fmt.Sprintf("SELECT width_bucket(age, $%d::int[]), count(*) FROM persons%s GROUP BY 1",
+		len(args)+1, condition)
+
+	rows, err := tx.QueryContext(ctx, query, append(args, pq.Array(edges))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int64, len(edges)+1)
+	for rows.Next() {
+		var bucket int
+		var count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		counts[bucket] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var histogram []model.AgeBucket
+	if edges[0] > 0 || counts[0] > 0 {
+		histogram = append(histogram, model.AgeBucket{To: &edges[0], Count: counts[0]})
+	}
+	for i := 1; i < len(edges); i++ {
+		histogram = append(histogram, model.AgeBucket{From: &edges[i-1], To: &edges[i], Count: counts[i]})
+	}
+	histogram = append(histogram, model.AgeBucket{From: &edges[len(edges)-1], Count: counts[len(edges)]})
+
+	return histogram, nil
+}
+
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
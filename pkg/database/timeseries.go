@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// CountCreated counts the persons matching filters that were created in
+// [from, to), per interval and, if groupBy is set, per value of that field.
+// Buckets are truncated in the named time zone and only non-empty ones are
+// returned.
+func (r *Repository) CountCreated(ctx context.Context, filters map[string]string, interval, timeZone, groupBy string, from, to time.Time) ([]model.BucketCount, error) {
+	conditions, args := filterConditions(filters)
+	n := len(args)
+	conditions = append(conditions, fmt.Sprintf("created_at >= $%d AND created_at < $%d", n+1, n+2))
+	args = append(args, from, to, interval, timeZone)
+
+	group := "NULL::text"
+	if model.TimeSeriesGroupFields[groupBy] {
+		group = groupBy
+	}
+	query := fmt.Sprintf(`
+        SELECT date_trunc($%d, created_at AT TIME ZONE $%d), %s, count(*)
+        FROM persons
+        WHERE %s
+        GROUP BY 1, 2
+        ORDER BY 1`, n+3, n+4, group, strings.Join(conditions, " AND "))
+
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []model.BucketCount
+	for rows.Next() {
+		var count model.BucketCount
+		if err := rows.Scan(&count.Start, &count.Value, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
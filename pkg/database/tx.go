@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/repository"
+	"github.com/lib/pq"
+)
+
+// isolationLevels maps the DB_TX_ISOLATION settings to isolation levels.
+var isolationLevels = map[string]sql.IsolationLevel{
+	"read_committed":  sql.LevelReadCommitted,
+	"repeatable_read": sql.LevelRepeatableRead,
+	"serializable":    sql.LevelSerializable,
+}
+
+// retryBackoff is the wait before the first retry of a transaction; each
+// further retry waits one more step.
+const retryBackoff = 10 * time.Millisecond
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn is the transaction the repository is bound to, or the pool.
+func (r *Repository) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// WithTx runs fn with a repository whose methods all share one transaction,
+// committed when fn returns nil and rolled back otherwise. A nil opts uses
+// the configured isolation level. The whole transaction, fn included, is
+// retried when PostgreSQL aborts it with a serialization failure or
+// deadlock, so fn must not have effects outside the repository it is given.
+// Called on a repository that is already in a transaction, fn joins it.
+func (r *Repository) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx repository.Repository) error) error {
+	return r.runTx(ctx, opts, func(tx *sql.Tx) error {
+		bound := *r
+		bound.tx = tx
+		return fn(&bound)
+	})
+}
+
+// inTx runs fn in a transaction with the configured isolation level.
+func (r *Repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return r.runTx(ctx, nil, fn)
+}
+
+func (r *Repository) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return r.duplicateError(ctx, fn(r.tx))
+	}
+	if opts == nil {
+		opts = &sql.TxOptions{Isolation: r.isolation}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.attemptTx(ctx, opts, fn)
+		if err == nil || !retryable(err) || attempt > r.maxRetries {
+			return r.duplicateError(ctx, err)
+		}
+		r.log.WithContext(ctx).Debugf("Retrying transaction after %v (retry %d of %d)", err, attempt, r.maxRetries)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * retryBackoff):
+		}
+	}
+}
+
+func (r *Repository) attemptTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// retryable reports whether err is a serialization failure or deadlock,
+// after which the transaction may succeed when run again.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/lib/pq"
+)
+
+// GetByNaturalKey returns the oldest live person with the given natural key
+// (see model.NaturalKey), or nil if there is none.
+func (r *Repository) GetByNaturalKey(ctx context.Context, key string) (*model.Person, error) {
+	query := "SELECT " + personColumns + " FROM persons WHERE name_key = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1"
+
+	person, err := scanPerson(r.conn().QueryRowContext(ctx, query, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return person, nil
+}
+
+// Upsert replaces the fields of the live person sharing person's natural key,
+// or creates person if there is none, and reports whether it was created.
+// Upserts of the same key are serialized with an advisory lock, so they
+// cannot both create even without the strict uniqueness policy. A non-zero
+// expectedVersion requires an existing person at that version.
+func (r *Repository) Upsert(ctx context.Context, person *model.Person, expectedVersion int64) (bool, error) {
+	key := model.NaturalKey(person.Name, person.Surname, person.Patronymic)
+	query := "SELECT " + personColumns + " FROM persons WHERE name_key = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1 FOR UPDATE"
+
+	created := false
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+			return err
+		}
+
+		before, err := scanPerson(tx.QueryRowContext(ctx, query, key))
+		if err == sql.ErrNoRows {
+			if expectedVersion != 0 {
+				return model.ErrVersionMismatch
+			}
+			created = true
+			return r.insertPerson(ctx, tx, person)
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && before.Version != expectedVersion {
+			return model.ErrVersionMismatch
+		}
+
+		person.ID = before.ID
+		after, err := r.updatePerson(ctx, tx, person, before.Version)
+		if err != nil {
+			return err
+		}
+		*person = *after
+
+		return insertAudit(ctx, tx, model.AuditUpdate, before, after)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
+
+// backfillBatchSize is how many rows BackfillNaturalKeys fills in per
+// transaction.
+const backfillBatchSize = 1000
+
+// BackfillNaturalKeys fills in name_key for rows written before it existed
+// and, under the strict uniqueness policy, natural_key for live persons that
+// lack it because they predate the policy. Of live persons sharing a key, the
+// oldest gets it; the ids of the others are returned so that they can be
+// merged, and until then they lack a natural_key. It is safe to run on every
+// start and from several replicas at once.
+func (r *Repository) BackfillNaturalKeys(ctx context.Context) ([]int64, error) {
+	for {
+		filled := 0
+		err := r.inTx(ctx, func(tx *sql.Tx) error {
+			var err error
+			filled, err = backfillNameKeys(ctx, tx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if filled < backfillBatchSize {
+			break
+		}
+	}
+	if !r.strictKeys {
+		return nil, nil
+	}
+
+	var collisions []int64
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('persons.natural_key'))"); err != nil {
+			return err
+		}
+		query := `
+            UPDATE persons p
+            SET natural_key = p.name_key
+            WHERE p.deleted_at IS NULL AND p.natural_key IS NULL
+              AND NOT EXISTS (
+                  SELECT 1 FROM persons o
+                  WHERE o.deleted_at IS NULL AND o.name_key = p.name_key AND o.id <> p.id
+                    AND (o.natural_key IS NOT NULL OR o.id < p.id))`
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM persons WHERE deleted_at IS NULL AND natural_key IS NULL ORDER BY id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		collisions = nil
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			collisions = append(collisions, id)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collisions, nil
+}
+
+// backfillNameKeys fills in name_key for up to backfillBatchSize rows and
+// returns how many it filled.
+func backfillNameKeys(ctx context.Context, tx *sql.Tx) (int, error) {
+	query := "SELECT id, name, surname, patronymic FROM persons WHERE name_key IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED"
+	rows, err := tx.QueryContext(ctx, query, backfillBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var keys []string
+	for rows.Next() {
+		var person model.Person
+		if err := rows.Scan(&person.ID, &person.Name, &person.Surname, &person.Patronymic); err != nil {
+			return 0, err
+		}
+		ids = append(ids, person.ID)
+		keys = append(keys, model.NaturalKey(person.Name, person.Surname, person.Patronymic))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	update := `
+        UPDATE persons p
+        SET name_key = v.key
+        FROM unnest($1::bigint[], $2::text[]) AS v(id, key)
+        WHERE p.id = v.id`
+	if _, err := tx.ExecContext(ctx, update, pq.Array(ids), pq.Array(keys)); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// personsAction dispatches custom methods on the persons collection, such as
+// POST /api/v1/persons:batch.
+func (h *Handler) personsAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":batch":
+		h.createPersonsBatch(c)
+	default:
+		h.render(c, 404, gin.H{"error": "Unknown action"})
+	}
+}
+
+// @Summary Create persons in bulk
+// @Description Validate, enrich and create up to BATCH_MAX_ITEMS persons. In atomic mode (the default) nothing is created unless every item is valid and inserted; in best_effort mode each item succeeds or fails on its own.
+// @Tags persons
+// @Accept json,xml,application/msgpack
+// @Produce json,xml,application/msgpack
+// @Param mode query string false "atomic or best_effort" default(atomic)
+// @Param persons body []model.PersonRequest true "Persons to create"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 207 {array} model.BatchItemResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 422 {array} model.BatchItemResult
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons:batch [post]
+func (h *Handler) createPersonsBatch(c *gin.Context) {
+	mode := c.DefaultQuery("mode", "atomic")
+	if mode != "atomic" && mode != "best_effort" {
+		h.logger(c).Debug("Invalid batch mode: ", mode)
+		h.render(c, 400, gin.H{"error": "Invalid mode"})
+		return
+	}
+	atomic := mode == "atomic"
+
+	var items []model.PersonRequest
+	var status int
+	var err error
+	if bodyFormat(c) == formatXML {
+		var envelope personRequestListXML
+		status, err = decodeBody(c, &envelope)
+		items = envelope.Persons
+	} else {
+		status, err = decodeBody(c, &items)
+	}
+	if err != nil {
+		h.logger(c).Debug("Invalid request: ", err)
+		h.render(c, status, gin.H{"error": err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		h.render(c, 400, gin.H{"error": "Batch is empty"})
+		return
+	}
+	if maxItems := h.cfg.Current().BatchMaxItems; len(items) > maxItems {
+		h.render(c, 413, gin.H{"error": fmt.Sprintf("Batch exceeds %d items", maxItems)})
+		return
+	}
+
+	results := make([]model.BatchItemResult, len(items))
+	var valid []model.PersonRequest
+	var validIndex []int
+	for i := range items {
+		results[i].Index = i
+		if err := binding.Validator.ValidateStruct(&items[i]); err != nil {
+			results[i].Status = 400
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, items[i])
+		validIndex = append(validIndex, i)
+	}
+
+	if atomic && len(valid) < len(items) {
+		for i := range results {
+			if results[i].Status == 0 {
+				results[i].Status = 424
+				results[i].Error = "Not created because other items are invalid"
+			}
+		}
+		h.render(c, 422, results)
+		return
+	}
+
+	persons, errs := h.service.CreateBatch(c.Request.Context(), valid, atomic)
+	for j, i := range validIndex {
+		if errs[j] != nil {
+			results[i].Status = errorStatus(errs[j])
+			results[i].Error = errs[j].Error()
+			continue
+		}
+		results[i].Status = 201
+		results[i].ID = &persons[j].ID
+	}
+
+	h.render(c, 207, results)
+}
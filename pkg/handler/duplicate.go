@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List likely duplicate persons
+// @Description Score pairs of persons with similar surnames on name, surname and patronymic similarity and matching age and nationality
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param person_id query int false "Only pairs involving this person"
+// @Param min_score query number false "Minimum score between 0 and 1" default(0.8)
+// @Param limit query int false "Maximum number of pairs" default(50)
+// @Success 200 {array} model.DuplicatePair
+// @Header 200 {boolean} X-Candidates-Truncated "Set when the scan stopped early; narrow it with person_id"
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/duplicates [get]
+func (h *Handler) getDuplicates(c *gin.Context) {
+	personID, err := strconv.ParseInt(c.DefaultQuery("person_id", "0"), 10, 64)
+	if err != nil || personID < 0 {
+		h.render(c, 400, gin.H{"error": "Invalid person_id"})
+		return
+	}
+	minScore, err := strconv.ParseFloat(c.DefaultQuery("min_score", "0.8"), 64)
+	if err != nil || minScore < 0 || minScore > 1 {
+		h.render(c, 400, gin.H{"error": "min_score must be between 0 and 1"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		h.render(c, 400, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	pairs, truncated, err := h.service.FindDuplicates(c.Request.Context(), personID, minScore, limit)
+	if err != nil {
+		h.logger(c).Errorf("Failed to find duplicates: %v", err)
+		h.render(c, 500, gin.H{"error": err.Error()})
+		return
+	}
+	if truncated {
+		c.Header("X-Candidates-Truncated", "true")
+	}
+	h.render(c, 200, pairs)
+}
+
+// @Summary Merge a duplicate into a person
+// @Description Fold the duplicate's fields into the person under the chosen strategy and soft-delete the duplicate. Individual fields can be pinned to either side.
+// @Tags persons
+// @Accept json,xml,application/msgpack
+// @Produce json,xml,application/msgpack
+// @Param id path int true "ID of the surviving person"
+// @Param merge body model.MergeRequest true "Duplicate and merge strategy"
+// @Param If-Match header string false "ETag the merge is conditional on"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} model.Person
+// @Header 200 {string} ETag "New version of the person"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/{id}/merge [post]
+func (h *Handler) mergePerson(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req model.MergeRequest
+	if status, err := bind(c, &req); err != nil {
+		h.logger(c).Debug("Invalid request: ", err)
+		h.render(c, status, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
+		return
+	}
+
+	person, err := h.service.Merge(c.Request.Context(), id, version, &req)
+	if err != nil {
+		h.logger(c).Errorf("Failed to merge person %d into %d: %v", req.DuplicateID, id, err)
+		h.render(c, errorStatus(err), errorBody(err))
+		return
+	}
+
+	c.Header("ETag", etag(person.Version))
+	h.render(c, 200, person)
+}
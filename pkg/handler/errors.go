@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errUnsupportedMediaType = errors.New("unsupported media type, supported types: application/json, application/xml, application/msgpack")
+	errEmptyBody            = errors.New("request body is empty")
+)
+
+// errorStatus maps service errors to HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInvalidInput):
+		return 400
+	case errors.Is(err, model.ErrNotFound), errors.Is(err, model.ErrNoSnapshot),
+		errors.Is(err, model.ErrImportNotFound):
+		return 404
+	case errors.Is(err, model.ErrNotDeleted), errors.Is(err, model.ErrDuplicatePerson),
+		errors.Is(err, model.ErrIdempotencyKeyInFlight):
+		return 409
+	case errors.Is(err, model.ErrVersionMismatch):
+		return 412
+	case errors.Is(err, model.ErrIdempotencyKeyReused):
+		return 422
+	default:
+		return 500
+	}
+}
+
+// errorBody is the response body for err. Writes rejected by the uniqueness
+// policy also carry the id of the person already holding the name.
+func errorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var duplicate *model.DuplicatePersonError
+	if errors.As(err, &duplicate) && duplicate.ExistingID != 0 {
+		body["existing_id"] = duplicate.ExistingID
+	}
+	return body
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func contextWithHeader(name, value string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("PUT", "/api/v1/persons/1", nil)
+	if value != "" {
+		c.Request.Header.Set(name, value)
+	}
+	return c
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		wantOK bool
+	}{
+		{header: "", want: 0, wantOK: true},
+		{header: "*", want: 0, wantOK: true},
+		{header: `"3"`, want: 3, wantOK: true},
+		{header: ` "3" `, want: 3, wantOK: true},
+		{header: `"3", "4"`, want: 3, wantOK: true},
+		{header: `W/"3"`, wantOK: false},
+		{header: `3`, wantOK: false},
+		{header: `"3`, wantOK: false},
+		{header: `""`, wantOK: false},
+		{header: `"0"`, wantOK: false},
+		{header: `"-1"`, wantOK: false},
+		{header: `"abc"`, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := ifMatchVersion(contextWithHeader("If-Match", tt.header))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ifMatchVersion(%q) = %d, %v; want %d, %v", tt.header, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: `"3"`, want: true},
+		{header: `W/"3"`, want: true},
+		{header: `"2", "3"`, want: true},
+		{header: "*", want: true},
+		{header: `"2"`, want: false},
+		{header: `"33"`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := ifNoneMatch(contextWithHeader("If-None-Match", tt.header), 3); got != tt.want {
+				t.Errorf("ifNoneMatch(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+	if got := etag(3); got != `"3"` {
+		t.Errorf("etag(3) = %s, want \"3\"", got)
+	}
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportFlushEvery is how many rows are written between flushes to the client.
+const exportFlushEvery = 1000
+
+// xlsxMaxRows is the row limit of an XLSX sheet; larger exports continue on
+// further sheets.
+const xlsxMaxRows = 1048576
+
+var exportColumns = []string{
+	"id", "name", "surname", "patronymic", "age", "gender", "nationality",
+	"created_at", "updated_at", "version", "deleted_at",
+}
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type personWriter interface {
+	Write(person *model.Person) error
+	Close() error
+}
+
+// @Summary Export persons
+// @Description Stream all persons matching the list filters as CSV, NDJSON or XLSX
+// @Tags persons
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string true "csv, ndjson or xlsx"
+// @Param name query string false "Filter by name"
+// @Param surname query string false "Filter by surname"
+// @Param age query int false "Filter by age"
+// @Param gender query string false "Filter by gender"
+// @Param nationality query string false "Filter by nationality"
+// @Param created_since query string false "Created at or after (RFC 3339)"
+// @Param created_before query string false "Created before (RFC 3339)"
+// @Param updated_since query string false "Updated at or after (RFC 3339)"
+// @Param updated_before query string false "Updated before (RFC 3339)"
+// @Param include_deleted query bool false "Include soft-deleted persons (admin only)"
+// @Param X-Admin-Token header string false "Admin token, required for include_deleted"
+// @Param sort query string false "Sort field (id, name, surname, age, created_at, updated_at), prefix with - for descending"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/export [get]
+func (h *Handler) exportPersons(c *gin.Context) {
+	format := c.Query("format")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		h.logger(c).Debug("Invalid export format: ", format)
+		h.render(c, 400, gin.H{"error": "Invalid format, expected csv, ndjson or xlsx"})
+		return
+	}
+
+	filters, sort, ok := h.listQuery(c)
+	if !ok {
+		return
+	}
+
+	// Large exports outlive the server's write timeout; the stream is bounded
+	// by the client going away instead.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger(c).Debugf("Failed to lift write deadline for export: %v", err)
+	}
+
+	filename := fmt.Sprintf("persons-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	var w personWriter
+	switch format {
+	case "csv":
+		w = newCSVPersonWriter(c.Writer)
+	case "ndjson":
+		w = &ndjsonPersonWriter{enc: json.NewEncoder(c.Writer)}
+	case "xlsx":
+		w = newXLSXPersonWriter(c.Writer)
+	}
+
+	rows := 0
+	err := h.service.Export(c.Request.Context(), filters, sort, func(person *model.Person) error {
+		if err := w.Write(person); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if f, ok := w.(interface{ Flush() }); ok {
+				f.Flush()
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		h.logger(c).Errorf("Failed to export persons: %v", err)
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			h.render(c, 500, gin.H{"error": err.Error()})
+		}
+		c.Abort()
+	}
+}
+
+func exportRecord(p *model.Person) []string {
+	record := []string{
+		strconv.FormatInt(p.ID, 10), p.Name, p.Surname, "", "", "", "",
+		p.CreatedAt.UTC().Format(time.RFC3339), p.UpdatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(p.Version, 10), "",
+	}
+	if p.Patronymic != nil {
+		record[3] = *p.Patronymic
+	}
+	if p.Age != nil {
+		record[4] = strconv.Itoa(*p.Age)
+	}
+	if p.Gender != nil {
+		record[5] = *p.Gender
+	}
+	if p.Nationality != nil {
+		record[6] = *p.Nationality
+	}
+	if p.DeletedAt != nil {
+		record[10] = p.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return record
+}
+
+type csvPersonWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVPersonWriter(w io.Writer) *csvPersonWriter {
+	return &csvPersonWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvPersonWriter) Write(person *model.Person) error {
+	if !w.header {
+		if err := w.w.Write(exportColumns); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	return w.w.Write(exportRecord(person))
+}
+
+func (w *csvPersonWriter) Flush() {
+	w.w.Flush()
+}
+
+func (w *csvPersonWriter) Close() error {
+	if !w.header {
+		if err := w.w.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type ndjsonPersonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonPersonWriter) Write(person *model.Person) error {
+	return w.enc.Encode(person)
+}
+
+func (w *ndjsonPersonWriter) Close() error {
+	return nil
+}
+
+// xlsxPersonWriter builds the workbook with excelize's stream writer, which
+// spills rows to a temporary file instead of keeping them in memory. The
+// workbook is sent once it is complete.
+type xlsxPersonWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	sheet  *excelize.StreamWriter
+	sheets int
+	row    int
+	err    error
+}
+
+func newXLSXPersonWriter(out io.Writer) *xlsxPersonWriter {
+	w := &xlsxPersonWriter{out: out, file: excelize.NewFile()}
+	w.err = w.nextSheet()
+	return w
+}
+
+func (w *xlsxPersonWriter) nextSheet() error {
+	if w.sheet != nil {
+		if err := w.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+
+	w.sheets++
+	name := "Persons"
+	if w.sheets > 1 {
+		name = fmt.Sprintf("Persons %d", w.sheets)
+	}
+	if w.sheets == 1 {
+		if err := w.file.SetSheetName("Sheet1", name); err != nil {
+			return err
+		}
+	} else if _, err := w.file.NewSheet(name); err != nil {
+		return err
+	}
+
+	sheet, err := w.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	w.sheet = sheet
+	w.row = 1
+
+	header := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	return w.writeRow(header)
+}
+
+func (w *xlsxPersonWriter) writeRow(values []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	if err := w.sheet.SetRow(cell, values); err != nil {
+		return err
+	}
+	w.row++
+	return nil
+}
+
+func (w *xlsxPersonWriter) Write(person *model.Person) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.row > xlsxMaxRows {
+		if err := w.nextSheet(); err != nil {
+			return err
+		}
+	}
+
+	record := exportRecord(person)
+	values := make([]interface{}, len(record))
+	for i, value := range record {
+		values[i] = value
+	}
+	values[0] = person.ID
+	if person.Age != nil {
+		values[4] = *person.Age
+	}
+	values[9] = person.Version
+	return w.writeRow(values)
+}
+
+func (w *xlsxPersonWriter) Close() error {
+	defer w.file.Close()
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.out)
+	return err
+}
@@ -1,38 +1,84 @@
 package handler
 
 import (
+	"crypto/subtle"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/Mukam21/server_Golang/pkg/config"
 	"github.com/Mukam21/server_Golang/pkg/model"
 	"github.com/Mukam21/server_Golang/pkg/service"
+	"github.com/Mukam21/server_Golang/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error      string `json:"error"`
+	ExistingID int64  `json:"existing_id,omitempty"`
 }
 
 type Handler struct {
-	service *service.Service
-	log     *logrus.Logger
+	service  *service.Service
+	log      *logrus.Logger
+	cfg      *config.Store
+	draining atomic.Bool
 }
 
-func NewHandler(service *service.Service, log *logrus.Logger) *Handler {
-	return &Handler{service: service, log: log}
+func NewHandler(service *service.Service, log *logrus.Logger, cfg *config.Store) *Handler {
+	return &Handler{service: service, log: log, cfg: cfg}
+}
+
+// logger returns a logger whose entries carry the request id and trace id.
+func (h *Handler) logger(c *gin.Context) *logrus.Entry {
+	return h.log.WithContext(c.Request.Context())
 }
 
 func (h *Handler) InitRoutes(r *gin.Engine) {
+	// Probes and scrapes are left out of traces.
+	r.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithGinFilter(func(c *gin.Context) bool {
+		return strings.HasPrefix(c.Request.URL.Path, "/api/")
+	})))
+	r.Use(requestContext, h.accessLog, recordMetrics)
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/healthz", h.healthz)
+	r.GET("/readyz", h.readyz)
+	r.GET("/health/dependencies", h.healthDependencies)
+
 	api := r.Group("/api/v1")
 	{
-		persons := api.Group("/persons")
+		api.POST("/persons:action", negotiateFormat, h.idempotent, h.personsAction)
+
+		// Downloads pick their own media type, so they skip Accept negotiation.
+		downloads := api.Group("/persons")
 		{
-			persons.POST("", h.createPerson)
+			downloads.GET("/export", h.exportPersons)
+			downloads.GET("/import/:job_id/errors", h.getImportErrors)
+		}
+
+		persons := api.Group("/persons", negotiateFormat)
+		{
+			persons.POST("", h.idempotent, h.createPerson)
+			persons.POST("/import", h.idempotent, h.importPersons)
+			persons.GET("/import/:job_id", h.getImport)
 			persons.GET("", h.getPersons)
+			persons.GET("/duplicates", h.getDuplicates)
+			persons.GET("/stats", h.getStats)
+			persons.GET("/stats/timeseries", h.getTimeSeries)
 			persons.GET("/:id", h.getPerson)
+			persons.PUT("", h.upsertPerson)
 			persons.PUT("/:id", h.updatePerson)
 			persons.PATCH("/:id", h.patchPerson)
 			persons.DELETE("/:id", h.deletePerson)
+			persons.POST("/:id/restore", h.idempotent, h.restorePerson)
+			persons.GET("/:id/history", h.getPersonHistory)
+			persons.POST("/:id/revert", h.idempotent, h.revertPerson)
+			persons.POST("/:id/merge", h.idempotent, h.mergePerson)
 		}
 	}
 }
@@ -40,34 +86,37 @@ func (h *Handler) InitRoutes(r *gin.Engine) {
 // @Summary Create a new person
 // @Description Create a person with name, surname, and optional patronymic
 // @Tags persons
-// @Accept json
-// @Produce json
+// @Accept json,xml,application/msgpack
+// @Produce json,xml,application/msgpack
 // @Param person body model.PersonRequest true "Person data"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Success 201 {object} model.Person
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/persons [post]
 func (h *Handler) createPerson(c *gin.Context) {
 	var req model.PersonRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.log.Debug("Invalid request: ", err)
-		c.JSON(400, gin.H{"error": err.Error()})
+	if status, err := bind(c, &req); err != nil {
+		h.logger(c).Debug("Invalid request: ", err)
+		h.render(c, status, gin.H{"error": err.Error()})
 		return
 	}
 
-	person, err := h.service.CreatePerson(&req)
+	person, err := h.service.CreatePerson(c.Request.Context(), &req)
 	if err != nil {
-		h.log.Errorf("Failed to create person: %v", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		h.logger(c).Errorf("Failed to create person: %v", err)
+		h.render(c, errorStatus(err), errorBody(err))
 		return
 	}
-	c.JSON(201, person)
+	h.render(c, 201, person)
 }
 
 // @Summary Get list of persons
 // @Description Retrieve persons with pagination and optional filters
 // @Tags persons
-// @Produce json
+// @Produce json,xml,application/msgpack,text/csv
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(10)
 // @Param name query string false "Filter by name"
@@ -75,169 +124,488 @@ func (h *Handler) createPerson(c *gin.Context) {
 // @Param age query int false "Filter by age"
 // @Param gender query string false "Filter by gender" enum(male,female,other)
 // @Param nationality query string false "Filter by nationality"
+// @Param created_since query string false "Created at or after (RFC 3339)"
+// @Param created_before query string false "Created before (RFC 3339)"
+// @Param updated_since query string false "Updated at or after (RFC 3339)"
+// @Param updated_before query string false "Updated before (RFC 3339)"
+// @Param include_deleted query bool false "Include soft-deleted persons (admin only)"
+// @Param X-Admin-Token header string false "Admin token, required for include_deleted"
+// @Param sort query string false "Sort field (id, name, surname, age, created_at, updated_at), prefix with - for descending"
 // @Success 200 {array} model.Person
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/persons [get]
 func (h *Handler) getPersons(c *gin.Context) {
 	pageStr := c.DefaultQuery("page", "1")
 	limitStr := c.DefaultQuery("limit", "10")
-	filters := map[string]string{
-		"name":        c.Query("name"),
-		"surname":     c.Query("surname"),
-		"age":         c.Query("age"),
-		"gender":      c.Query("gender"),
-		"nationality": c.Query("nationality"),
-	}
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
-		h.log.Debug("Invalid page number: ", pageStr)
-		c.JSON(400, gin.H{"error": "Invalid page number"})
+		h.logger(c).Debug("Invalid page number: ", pageStr)
+		h.render(c, 400, gin.H{"error": "Invalid page number"})
 		return
 	}
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 {
-		h.log.Debug("Invalid limit: ", limitStr)
-		c.JSON(400, gin.H{"error": "Invalid limit"})
+		h.logger(c).Debug("Invalid limit: ", limitStr)
+		h.render(c, 400, gin.H{"error": "Invalid limit"})
 		return
 	}
 
-	persons, err := h.service.GetAll(page, limit, filters)
+	filters, sort, ok := h.listQuery(c)
+	if !ok {
+		return
+	}
+
+	persons, err := h.service.GetAll(c.Request.Context(), page, limit, filters, sort)
 	if err != nil {
-		h.log.Errorf("Failed to get persons: %v", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		h.logger(c).Errorf("Failed to get persons: %v", err)
+		h.render(c, 500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, persons)
+	h.render(c, 200, persons)
+}
+
+// listQuery reads the filters and sort order shared by the list, export and
+// stats endpoints. On invalid input it writes the error response and returns
+// ok == false.
+func (h *Handler) listQuery(c *gin.Context) (filters map[string]string, sort model.Sort, ok bool) {
+	filters = map[string]string{
+		"name":           c.Query("name"),
+		"surname":        c.Query("surname"),
+		"age":            c.Query("age"),
+		"gender":         c.Query("gender"),
+		"nationality":    c.Query("nationality"),
+		"created_since":  c.Query("created_since"),
+		"created_before": c.Query("created_before"),
+		"updated_since":  c.Query("updated_since"),
+		"updated_before": c.Query("updated_before"),
+	}
+
+	for _, key := range []string{"created_since", "created_before", "updated_since", "updated_before"} {
+		if value := filters[key]; value != "" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				h.logger(c).Debugf("Invalid %s: %s", key, value)
+				h.render(c, 400, gin.H{"error": "Invalid " + key})
+				return nil, model.Sort{}, false
+			}
+		}
+	}
+
+	if c.Query("include_deleted") == "true" {
+		if !h.isAdmin(c) {
+			h.render(c, 403, gin.H{"error": "include_deleted requires admin access"})
+			return nil, model.Sort{}, false
+		}
+		filters["include_deleted"] = "true"
+	}
+
+	sort, err := model.ParseSort(c.Query("sort"))
+	if err != nil {
+		h.logger(c).Debug("Invalid sort: ", c.Query("sort"))
+		h.render(c, 400, gin.H{"error": err.Error()})
+		return nil, model.Sort{}, false
+	}
+
+	return filters, sort, true
 }
 
 // @Summary Get person by ID
-// @Description Retrieve a person by their ID
+// @Description Retrieve a person by their ID, optionally as it was at a point in time
 // @Tags persons
-// @Produce json
+// @Produce json,xml,application/msgpack
 // @Param id path int true "Person ID"
+// @Param as_of query string false "Return the person as of this time (RFC 3339)"
+// @Param include_deleted query bool false "Return the person even if soft-deleted (admin only)"
+// @Param X-Admin-Token header string false "Admin token, required for include_deleted"
+// @Param If-None-Match header string false "ETag of a cached representation"
 // @Success 200 {object} model.Person
+// @Header 200 {string} ETag "Current version of the person"
+// @Success 304 "Not modified"
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/persons/{id} [get]
 func (h *Handler) getPerson(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		h.log.Debug("Invalid ID: ", c.Param("id"))
-		c.JSON(400, gin.H{"error": "Invalid ID"})
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	includeDeleted := c.Query("include_deleted") == "true"
+	if includeDeleted && !h.isAdmin(c) {
+		h.render(c, 403, gin.H{"error": "include_deleted requires admin access"})
 		return
 	}
 
-	person, err := h.service.GetByID(id)
+	if asOfStr := c.Query("as_of"); asOfStr != "" {
+		asOf, err := time.Parse(time.RFC3339, asOfStr)
+		if err != nil {
+			h.logger(c).Debug("Invalid as_of: ", asOfStr)
+			h.render(c, 400, gin.H{"error": "Invalid as_of"})
+			return
+		}
+
+		person, err := h.service.GetAsOf(c.Request.Context(), id, asOf, includeDeleted)
+		if err != nil {
+			h.logger(c).Errorf("Failed to get person with ID %d as of %s: %v", id, asOfStr, err)
+			h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		h.render(c, 200, person)
+		return
+	}
+
+	person, err := h.service.GetByID(c.Request.Context(), id, includeDeleted)
 	if err != nil {
-		h.log.Errorf("Failed to get person with ID %d: %v", id, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		h.logger(c).Errorf("Failed to get person with ID %d: %v", id, err)
+		h.render(c, 500, gin.H{"error": err.Error()})
 		return
 	}
 	if person == nil {
-		c.JSON(404, gin.H{"error": "Person not found"})
+		h.render(c, 404, gin.H{"error": "Person not found"})
+		return
+	}
+
+	c.Header("ETag", etag(person.Version))
+	if ifNoneMatch(c, person.Version) {
+		c.Status(304)
 		return
 	}
-	c.JSON(200, person)
+	h.render(c, 200, person)
 }
 
 // @Summary Update a person
 // @Description Update person details by ID
 // @Tags persons
-// @Accept json
-// @Produce json
+// @Accept json,xml,application/msgpack
+// @Produce json,xml,application/msgpack
 // @Param id path int true "Person ID"
+// @Param If-Match header string false "ETag the update is conditional on"
 // @Param person body model.Person true "Updated person data"
 // @Success 200 {object} map[string]string
+// @Header 200 {string} ETag "New version of the person"
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/persons/{id} [put]
 func (h *Handler) updatePerson(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		h.log.Debug("Invalid ID: ", c.Param("id"))
-		c.JSON(400, gin.H{"error": "Invalid ID"})
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
 		return
 	}
 
 	var person model.Person
-	if err := c.ShouldBindJSON(&person); err != nil {
-		h.log.Debug("Invalid request: ", err)
-		c.JSON(400, gin.H{"error": err.Error()})
+	if status, err := bind(c, &person); err != nil {
+		h.logger(c).Debug("Invalid request: ", err)
+		h.render(c, status, gin.H{"error": err.Error()})
 		return
 	}
 	person.ID = id
 
-	if err := h.service.Update(&person); err != nil {
-		h.log.Errorf("Failed to update person with ID %d: %v", id, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Person updated"})
+	if err := h.service.Update(c.Request.Context(), &person, version); err != nil {
+		h.logger(c).Errorf("Failed to update person with ID %d: %v", id, err)
+		h.render(c, errorStatus(err), errorBody(err))
+		return
+	}
+
+	c.Header("ETag", etag(person.Version))
+	h.render(c, 200, gin.H{"message": "Person updated"})
+}
+
+// @Summary Create or replace a person by natural key
+// @Description Replace the person with the same case-insensitive name, surname and patronymic, or create one if there is none. Age, gender and nationality left out of a new person are enriched.
+// @Tags persons
+// @Accept json,xml,application/msgpack
+// @Produce json,xml,application/msgpack
+// @Param If-Match header string false "ETag of the existing person the replace is conditional on"
+// @Param person body model.Person true "Person data"
+// @Success 200 {object} model.Person
+// @Success 201 {object} model.Person
+// @Header 200,201 {string} ETag "New version of the person"
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons [put]
+func (h *Handler) upsertPerson(c *gin.Context) {
+	var person model.Person
+	if status, err := bind(c, &person); err != nil {
+		h.logger(c).Debug("Invalid request: ", err)
+		h.render(c, status, gin.H{"error": err.Error()})
+		return
+	}
+	person.ID = 0
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
+		return
+	}
+
+	created, err := h.service.Upsert(c.Request.Context(), &person, version)
+	if err != nil {
+		h.logger(c).Errorf("Failed to upsert person: %v", err)
+		h.render(c, errorStatus(err), errorBody(err))
+		return
+	}
+
+	c.Header("ETag", etag(person.Version))
+	if created {
+		h.render(c, 201, &person)
+		return
+	}
+	h.render(c, 200, &person)
 }
 
 // @Summary Partially update a person
 // @Description Update specific fields of a person by ID
 // @Tags persons
-// @Accept json
-// @Produce json
+// @Accept json,xml,application/msgpack
+// @Produce json,xml,application/msgpack
 // @Param id path int true "Person ID"
+// @Param If-Match header string false "ETag the update is conditional on"
 // @Param person body model.PersonPatchRequest true "Fields to update"
 // @Success 200 {object} map[string]string
+// @Header 200 {string} ETag "New version of the person"
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/persons/{id} [patch]
 func (h *Handler) patchPerson(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		h.log.Debug("Invalid ID: ", c.Param("id"))
-		c.JSON(400, gin.H{"error": "Invalid ID"})
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
 		return
 	}
 
 	var patch model.PersonPatchRequest
-	if err := c.ShouldBindJSON(&patch); err != nil {
-		h.log.Debug("Invalid request: ", err)
-		c.JSON(400, gin.H{"error": err.Error()})
+	if status, err := bind(c, &patch); err != nil {
+		h.logger(c).Debug("Invalid request: ", err)
+		h.render(c, status, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
 		return
 	}
 
-	if err := h.service.Patch(id, &patch); err != nil {
-		h.log.Errorf("Failed to patch person with ID %d: %v", id, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+	person, err := h.service.Patch(c.Request.Context(), id, version, &patch)
+	if err != nil {
+		h.logger(c).Errorf("Failed to patch person with ID %d: %v", id, err)
+		h.render(c, errorStatus(err), errorBody(err))
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Person patched"})
+	c.Header("ETag", etag(person.Version))
+	h.render(c, 200, gin.H{"message": "Person patched"})
 }
 
 // @Summary Delete a person
-// @Description Delete a person by ID
+// @Description Soft-delete a person by ID. Deleted persons can be restored until purged.
 // @Tags persons
-// @Produce json
+// @Produce json,xml,application/msgpack
 // @Param id path int true "Person ID"
+// @Param If-Match header string false "ETag the deletion is conditional on"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/persons/{id} [delete]
 func (h *Handler) deletePerson(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		h.log.Debug("Invalid ID: ", c.Param("id"))
-		c.JSON(400, gin.H{"error": "Invalid ID"})
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id, version); err != nil {
+		h.logger(c).Errorf("Failed to delete person with ID %d: %v", id, err)
+		h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.render(c, 200, gin.H{"message": "Person deleted"})
+}
+
+// @Summary Restore a deleted person
+// @Description Undo a soft delete. Requires the X-Admin-Token header.
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param id path int true "Person ID"
+// @Param X-Admin-Token header string true "Admin token"
+// @Param If-Match header string false "ETag the restore is conditional on"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} model.Person
+// @Header 200 {string} ETag "New version of the person"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/{id}/restore [post]
+func (h *Handler) restorePerson(c *gin.Context) {
+	if !h.isAdmin(c) {
+		h.render(c, 403, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
+		return
+	}
+
+	person, err := h.service.Restore(c.Request.Context(), id, version)
+	if err != nil {
+		h.logger(c).Errorf("Failed to restore person with ID %d: %v", id, err)
+		h.render(c, errorStatus(err), errorBody(err))
+		return
+	}
+
+	c.Header("ETag", etag(person.Version))
+	h.render(c, 200, person)
+}
+
+// @Summary Revert a person to an earlier version
+// @Description Restore the state recorded at the given version as a new version
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param id path int true "Person ID"
+// @Param version query int true "Version to revert to"
+// @Param If-Match header string false "ETag the revert is conditional on"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} model.Person
+// @Header 200 {string} ETag "New version of the person"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/{id}/revert [post]
+func (h *Handler) revertPerson(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	target, err := strconv.ParseInt(c.Query("version"), 10, 64)
+	if err != nil || target < 1 {
+		h.logger(c).Debug("Invalid version: ", c.Query("version"))
+		h.render(c, 400, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		h.render(c, 412, gin.H{"error": "If-Match does not match the current version"})
+		return
+	}
+
+	person, err := h.service.Revert(c.Request.Context(), id, target, version)
+	if err != nil {
+		h.logger(c).Errorf("Failed to revert person with ID %d to version %d: %v", id, target, err)
+		h.render(c, errorStatus(err), errorBody(err))
+		return
+	}
+
+	c.Header("ETag", etag(person.Version))
+	h.render(c, 200, person)
+}
+
+// @Summary Get change history of a person
+// @Description List audit entries for a person, newest first
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param id path int true "Person ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {array} model.AuditEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/{id}/history [get]
+func (h *Handler) getPersonHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger(c).Debug("Invalid ID: ", c.Param("id"))
+		h.render(c, 400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		h.logger(c).Debug("Invalid page number: ", pageStr)
+		h.render(c, 400, gin.H{"error": "Invalid page number"})
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "10")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		h.logger(c).Debug("Invalid limit: ", limitStr)
+		h.render(c, 400, gin.H{"error": "Invalid limit"})
 		return
 	}
 
-	if err := h.service.Delete(id); err != nil {
-		h.log.Errorf("Failed to delete person with ID %d: %v", id, err)
-		c.JSON(500, gin.H{"error": err.Error()})
+	entries, err := h.service.History(c.Request.Context(), id, page, limit)
+	if err != nil {
+		h.logger(c).Errorf("Failed to get history of person with ID %d: %v", id, err)
+		h.render(c, 500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Person deleted"})
+	h.render(c, 200, entries)
+}
+
+// isAdmin reports whether the request carries the configured admin token.
+// Admin access is disabled entirely when no token is configured.
+func (h *Handler) isAdmin(c *gin.Context) bool {
+	token := c.GetHeader("X-Admin-Token")
+	adminToken := h.cfg.Current().AdminToken
+	if adminToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
 }
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// The probes are served outside the API group, so they stay out of the
+// Swagger docs like the docs themselves.
+
+// StartDraining makes the readiness probe fail from now on, so that load
+// balancers stop sending requests while those in flight finish.
+func (h *Handler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// healthz is the liveness probe: answering at all means the process is
+// alive, so it checks nothing else.
+func (h *Handler) healthz(c *gin.Context) {
+	c.JSON(200, model.HealthReport{Status: model.HealthUp})
+}
+
+// readyz is the readiness probe.
+func (h *Handler) readyz(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(503, model.HealthReport{Status: model.HealthDraining})
+		return
+	}
+	h.healthReport(c, h.service.Readiness(c.Request.Context()))
+}
+
+func (h *Handler) healthDependencies(c *gin.Context) {
+	h.healthReport(c, h.service.Dependencies(c.Request.Context()))
+}
+
+// healthReport responds 503 when a critical check is down, 200 otherwise.
+func (h *Handler) healthReport(c *gin.Context, report *model.HealthReport) {
+	code := 200
+	if report.Status == model.HealthDown {
+		code = 503
+		for _, check := range report.Checks {
+			if check.Status == model.HealthDown {
+				h.logger(c).Warnf("Health check %s failed: %s", check.Name, check.Error)
+			}
+		}
+	}
+	c.JSON(code, report)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeaders are the response headers stored with an idempotent response.
+var replayedHeaders = []string{"Content-Type", "Content-Disposition", "ETag", "Location"}
+
+// responseRecorder keeps a copy of everything written to the response.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent makes a POST safe to retry when the client sends an
+// Idempotency-Key: the first request with a key runs and its response is
+// stored, repeats of it get the stored response, a different request with the
+// same key gets 422 and a repeat that arrives while the first is still
+// running gets 409. Server errors are not stored, so they can be retried.
+func (h *Handler) idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxHeaderValueLen {
+		h.render(c, 400, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		c.Abort()
+		return
+	}
+
+	// Bodies over the import limit are rejected by the handler anyway, so
+	// reading one byte past it is enough to tell them apart.
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(h.cfg.Current().ImportMaxBytes)+1))
+	if err != nil {
+		h.render(c, 400, gin.H{"error": "failed to read request body"})
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx := c.Request.Context()
+	token, stored, err := h.service.ClaimIdempotencyKey(ctx, key, requestHash(c.Request, c.ContentType(), body))
+	if err != nil {
+		h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+	if stored != nil {
+		for name, value := range stored.Headers {
+			c.Header(name, value)
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(stored.StatusCode)
+		c.Writer.Write(stored.Body)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// The client may have gone away; the outcome must be recorded regardless.
+	ctx = context.WithoutCancel(ctx)
+	status := recorder.Status()
+	if status >= 500 {
+		h.service.ReleaseIdempotencyKey(ctx, key, token)
+		return
+	}
+
+	resp := &model.IdempotentResponse{
+		StatusCode: status,
+		Headers:    make(map[string]string),
+		Body:       recorder.body.Bytes(),
+	}
+	for _, name := range replayedHeaders {
+		if value := recorder.Header().Get(name); value != "" {
+			resp.Headers[name] = value
+		}
+	}
+	if err := h.service.SaveIdempotentResponse(ctx, key, token, resp); err != nil {
+		h.service.ReleaseIdempotencyKey(ctx, key, token)
+	}
+}
+
+// requestHash identifies a request for an Idempotency-Key. The query is
+// hashed in its canonical form, so retries that order or escape the same
+// parameters differently still match.
+func requestHash(r *http.Request, contentType string, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.EscapedPath()+"?"+r.URL.Query().Encode()+"\n")
+	io.WriteString(hash, contentType+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestHash(t *testing.T) {
+	base := requestHash(httptest.NewRequest("POST", "/api/v1/persons/import?mode=upsert&dry_run=true", nil), "text/csv", []byte("a,b"))
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		same        bool
+	}{
+		{"identical", "POST", "/api/v1/persons/import?mode=upsert&dry_run=true", "text/csv", "a,b", true},
+		{"parameters reordered", "POST", "/api/v1/persons/import?dry_run=true&mode=upsert", "text/csv", "a,b", true},
+		{"parameter escaped", "POST", "/api/v1/persons/import?mode=%75psert&dry_run=true", "text/csv", "a,b", true},
+		{"different parameter", "POST", "/api/v1/persons/import?mode=insert&dry_run=true", "text/csv", "a,b", false},
+		{"missing parameter", "POST", "/api/v1/persons/import?mode=upsert", "text/csv", "a,b", false},
+		{"different path", "POST", "/api/v1/persons?mode=upsert&dry_run=true", "text/csv", "a,b", false},
+		{"different method", "PUT", "/api/v1/persons/import?mode=upsert&dry_run=true", "text/csv", "a,b", false},
+		{"different content type", "POST", "/api/v1/persons/import?mode=upsert&dry_run=true", "application/json", "a,b", false},
+		{"different body", "POST", "/api/v1/persons/import?mode=upsert&dry_run=true", "text/csv", "a,c", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestHash(httptest.NewRequest(tt.method, tt.target, nil), tt.contentType, []byte(tt.body))
+			if (got == base) != tt.same {
+				t.Errorf("hash equal to base = %v, want %v", got == base, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/Mukam21/server_Golang/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Import persons from a spreadsheet
+// @Description Upload a CSV or XLSX file whose rows go through the same validation and enrichment as single creates. Large files, or any file with async=true, are processed in the background.
+// @Tags persons
+// @Accept multipart/form-data
+// @Produce json,xml,application/msgpack
+// @Param file formData file true "CSV or XLSX file"
+// @Param format formData string false "csv or xlsx, detected from the file name by default"
+// @Param mapping formData string false "JSON object mapping name, surname and patronymic to column headers"
+// @Param dry_run formData bool false "Validate only, create nothing"
+// @Param dedup formData string false "none, file, existing or both" default(none)
+// @Param async formData bool false "Process in the background"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} model.ImportJob
+// @Success 202 {object} model.ImportJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/import [post]
+func (h *Handler) importPersons(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.cfg.Current().ImportMaxBytes))
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.render(c, 413, gin.H{"error": "File is too large"})
+			return
+		}
+		h.logger(c).Debug("Invalid upload: ", err)
+		h.render(c, 400, gin.H{"error": "File is required"})
+		return
+	}
+
+	opts := service.ImportOptions{
+		Format: strings.ToLower(c.PostForm("format")),
+		DryRun: c.PostForm("dry_run") == "true",
+		Dedup:  c.DefaultPostForm("dedup", "none"),
+		Async:  c.PostForm("async") == "true",
+	}
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+	if opts.Format != "csv" && opts.Format != "xlsx" {
+		h.render(c, 400, gin.H{"error": "Only csv and xlsx files are supported"})
+		return
+	}
+	switch opts.Dedup {
+	case "none", "file", "existing", "both":
+	default:
+		h.render(c, 400, gin.H{"error": "Invalid dedup"})
+		return
+	}
+	if mapping := c.PostForm("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			h.logger(c).Debug("Invalid mapping: ", err)
+			h.render(c, 400, gin.H{"error": "Invalid mapping"})
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.logger(c).Errorf("Failed to open upload: %v", err)
+		h.render(c, 500, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	job, err := h.service.StartImport(c.Request.Context(), file, opts)
+	if err != nil {
+		h.logger(c).Errorf("Failed to import persons: %v", err)
+		h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	status := 200
+	if job.FinishedAt == nil {
+		status = 202
+	}
+	h.render(c, status, withReportURL(job))
+}
+
+// @Summary Get an import job
+// @Description Report the progress and outcome of a spreadsheet import
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param job_id path string true "Import job ID"
+// @Success 200 {object} model.ImportJob
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/import/{job_id} [get]
+func (h *Handler) getImport(c *gin.Context) {
+	job, err := h.service.GetImport(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.render(c, 200, withReportURL(job))
+}
+
+// @Summary Download the error report of an import
+// @Description CSV listing the row number and reason of every rejected row
+// @Tags persons
+// @Produce text/csv
+// @Param job_id path string true "Import job ID"
+// @Success 200 {file} file
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/import/{job_id}/errors [get]
+func (h *Handler) getImportErrors(c *gin.Context) {
+	ctx := c.Request.Context()
+	job, err := h.service.GetImport(ctx, c.Param("job_id"))
+	if err != nil {
+		h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if job.FinishedAt == nil {
+		h.render(c, 409, gin.H{"error": "Import is still running"})
+		return
+	}
+	rowErrs, err := h.service.ImportErrors(ctx, job.ID)
+	if err != nil {
+		h.render(c, errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="import-`+job.ID+`-errors.csv"`)
+	c.Status(200)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"row", "reason"})
+	for _, rowErr := range rowErrs {
+		w.Write([]string{strconv.Itoa(rowErr.Row), rowErr.Reason})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		h.logger(c).Errorf("Failed to write import report %s: %v", job.ID, err)
+	}
+}
+
+func withReportURL(job *model.ImportJob) *model.ImportJob {
+	if job.Rejected > 0 {
+		job.ReportURL = "/api/v1/persons/import/" + job.ID + "/errors"
+	}
+	return job
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Mukam21/server_Golang/pkg/metrics"
+	"github.com/Mukam21/server_Golang/pkg/requestctx"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxHeaderValueLen matches the width, in characters, of the audit columns
+// the values end up in.
+const maxHeaderValueLen = 255
+
+// requestContext copies the caller identity and request id headers into the
+// request context, where the audit trail and the logs pick them up. Requests
+// without an X-Request-ID get a generated one, which is echoed back.
+func requestContext(c *gin.Context) {
+	ctx := c.Request.Context()
+	if actor := headerValue(c, "X-Actor"); actor != "" {
+		ctx = requestctx.WithActor(ctx, actor)
+	}
+	requestID := headerValue(c, "X-Request-ID")
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	ctx = requestctx.WithRequestID(ctx, requestID)
+	c.Header("X-Request-ID", requestID)
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessLog logs every request once it has been handled, in place of gin's
+// own logger. Only the path is logged: query strings may hold names.
+func (h *Handler) accessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	status := c.Writer.Status()
+	entry := h.logger(c).WithFields(logrus.Fields{
+		"method":     c.Request.Method,
+		"path":       c.Request.URL.Path,
+		"route":      c.FullPath(),
+		"status":     status,
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"client_ip":  c.ClientIP(),
+		"bytes":      c.Writer.Size(),
+	})
+	switch {
+	case status >= 500:
+		entry.Error("Request failed")
+	case status >= 400:
+		entry.Warn("Request rejected")
+	default:
+		entry.Info("Request handled")
+	}
+}
+
+// headerValue returns the header key cut to maxHeaderValueLen characters,
+// which is how the audit columns count, with invalid UTF-8 dropped, since
+// PostgreSQL rejects it.
+func headerValue(c *gin.Context, key string) string {
+	value := strings.ToValidUTF8(c.GetHeader(key), "")
+	if utf8.RuneCountInString(value) > maxHeaderValueLen {
+		value = string([]rune(value)[:maxHeaderValueLen])
+	}
+	return value
+}
+
+// metricMethods are the HTTP methods recorded by name; any other method a
+// client makes up is recorded as "OTHER", so it cannot add series.
+var metricMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// recordMetrics counts and times requests by route template, so /persons/1
+// and /persons/2 share a series. Requests matching no route are grouped
+// under "unmatched".
+func recordMetrics(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	method := c.Request.Method
+	if !metricMethods[method] {
+		method = "OTHER"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+	metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+}
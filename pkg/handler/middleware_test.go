@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Mukam21/server_Golang/pkg/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordMetricsMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(recordMetrics)
+	r.Handle("GET", "/metrics-test", func(c *gin.Context) { c.Status(200) })
+
+	tests := []struct {
+		method string
+		label  string
+	}{
+		{"GET", "GET"},
+		{"OPTIONS", "OPTIONS"},
+		{"PROPFIND", "OTHER"},
+		{"X-MADE-UP", "OTHER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(tt.label, "unmatched", "404")
+			if tt.method == "GET" {
+				counter = metrics.HTTPRequests.WithLabelValues(tt.label, "/metrics-test", "200")
+			}
+			before := testutil.ToFloat64(counter)
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/metrics-test", nil))
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("requests recorded under method %q = %v, want 1", tt.label, got)
+			}
+		})
+	}
+	if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("X-MADE-UP", "unmatched", "404")); got != 0 {
+		t.Errorf("made-up method recorded under its own name %v times", got)
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"short", "Иван", "Иван"},
+		{"multibyte at the limit", strings.Repeat("ж", maxHeaderValueLen), strings.Repeat("ж", maxHeaderValueLen)},
+		{"multibyte over the limit", strings.Repeat("ж", maxHeaderValueLen+10), strings.Repeat("ж", maxHeaderValueLen)},
+		{"odd byte offset", "a" + strings.Repeat("ж", maxHeaderValueLen), "a" + strings.Repeat("ж", maxHeaderValueLen-1)},
+		{"invalid UTF-8 is dropped", "ok\xff\xfeok", "okok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			c.Request.Header.Set("X-Actor", tt.value)
+
+			got := headerValue(c, "X-Actor")
+			if got != tt.want {
+				t.Errorf("headerValue = %q (%d characters), want %d characters", got, utf8.RuneCountInString(got), utf8.RuneCountInString(tt.want))
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("headerValue returned invalid UTF-8 %q", got)
+			}
+		})
+	}
+}
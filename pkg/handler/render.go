@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	mimeJSON     = "application/json"
+	mimeXML      = "application/xml"
+	mimeTextXML  = "text/xml"
+	mimeMsgPack  = "application/msgpack"
+	mimeXMsgPack = "application/x-msgpack"
+	mimeCSV      = "text/csv"
+)
+
+const (
+	formatUnknown = ""
+	formatJSON    = "json"
+	formatXML     = "xml"
+	formatMsgPack = "msgpack"
+	formatCSV     = "csv"
+)
+
+// formatKey is the gin context key negotiateFormat stores the format under.
+const formatKey = "response_format"
+
+// responseTypes are the media types responses can be rendered as, in order
+// of preference when the client accepts several. listResponseTypes adds CSV
+// for the routes in csvRoutes.
+var (
+	responseTypes     = []string{mimeJSON, mimeXML, mimeTextXML, mimeMsgPack, mimeXMsgPack}
+	listResponseTypes = append(responseTypes[:len(responseTypes):len(responseTypes)], mimeCSV)
+)
+
+// csvRoutes are the routes, by method and path pattern, that answer with a
+// list of persons, the only responses CSV can represent.
+var csvRoutes = map[string]bool{
+	"GET /api/v1/persons": true,
+}
+
+var mediaFormats = map[string]string{
+	mimeJSON:     formatJSON,
+	mimeXML:      formatXML,
+	mimeTextXML:  formatXML,
+	mimeMsgPack:  formatMsgPack,
+	mimeXMsgPack: formatMsgPack,
+	mimeCSV:      formatCSV,
+}
+
+// XML needs a single root element, so lists are wrapped in envelopes.
+type personListXML struct {
+	XMLName xml.Name        `xml:"persons"`
+	Persons []*model.Person `xml:"person"`
+}
+
+type auditEntryListXML struct {
+	XMLName xml.Name            `xml:"history"`
+	Entries []*model.AuditEntry `xml:"entry"`
+}
+
+type batchResultListXML struct {
+	XMLName xml.Name                `xml:"results"`
+	Results []model.BatchItemResult `xml:"result"`
+}
+
+type duplicatePairListXML struct {
+	XMLName xml.Name               `xml:"duplicates"`
+	Pairs   []*model.DuplicatePair `xml:"duplicate"`
+}
+
+type personRequestListXML struct {
+	XMLName xml.Name              `xml:"persons"`
+	Persons []model.PersonRequest `xml:"person"`
+}
+
+// negotiateFormat rejects requests whose Accept header matches none of the
+// route's response types with 406 before the handler does any work.
+func negotiateFormat(c *gin.Context) {
+	types, supported := responseTypes, "application/json, application/xml, application/msgpack"
+	if csvRoutes[c.Request.Method+" "+c.FullPath()] {
+		types, supported = listResponseTypes, supported+", text/csv"
+	}
+	mime := c.NegotiateFormat(types...)
+	if mime == "" {
+		c.AbortWithStatusJSON(406, gin.H{"error": "Not acceptable, supported types: " + supported})
+		return
+	}
+	c.Set(formatKey, mediaFormats[mime])
+	c.Next()
+}
+
+// render writes obj in the format negotiated from the Accept header. CSV is
+// only negotiated for person lists; error responses fall back to JSON.
+func (h *Handler) render(c *gin.Context, code int, obj interface{}) {
+	format := c.GetString(formatKey)
+	if format == formatUnknown {
+		format = mediaFormats[c.NegotiateFormat(responseTypes...)]
+	}
+
+	switch format {
+	case formatXML:
+		c.XML(code, xmlEnvelope(obj))
+	case formatMsgPack:
+		c.Render(code, msgPackRender{obj})
+	case formatCSV:
+		persons, ok := obj.([]*model.Person)
+		if !ok {
+			c.JSON(code, obj)
+			return
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(code)
+		w := newCSVPersonWriter(c.Writer)
+		for _, person := range persons {
+			if err := w.Write(person); err != nil {
+				h.logger(c).Errorf("Failed to write CSV response: %v", err)
+				return
+			}
+		}
+		if err := w.Close(); err != nil {
+			h.logger(c).Errorf("Failed to write CSV response: %v", err)
+		}
+	default:
+		c.JSON(code, obj)
+	}
+}
+
+func xmlEnvelope(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case []*model.Person:
+		return personListXML{Persons: v}
+	case []*model.AuditEntry:
+		return auditEntryListXML{Entries: v}
+	case []model.BatchItemResult:
+		return batchResultListXML{Results: v}
+	case []*model.DuplicatePair:
+		return duplicatePairListXML{Pairs: v}
+	default:
+		return obj
+	}
+}
+
+// msgPackRender renders MessagePack with the same codec gin uses for binding,
+// so field names follow the json tags.
+type msgPackRender struct {
+	data interface{}
+}
+
+func (r msgPackRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return codec.NewEncoder(w, new(codec.MsgpackHandle)).Encode(r.data)
+}
+
+func (r msgPackRender) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", mimeMsgPack)
+	}
+}
+
+// bodyFormat returns the format of the request body from its Content-Type.
+// Requests without a Content-Type are treated as JSON.
+func bodyFormat(c *gin.Context) string {
+	contentType := c.ContentType()
+	if contentType == "" {
+		return formatJSON
+	}
+	format := mediaFormats[contentType]
+	if format == formatCSV {
+		return formatUnknown
+	}
+	return format
+}
+
+// decodeBody decodes the request body according to its Content-Type without
+// validating it. The returned status is 415 for unsupported media types and
+// 400 for malformed bodies.
+func decodeBody(c *gin.Context, obj interface{}) (int, error) {
+	var err error
+	switch bodyFormat(c) {
+	case formatJSON:
+		err = json.NewDecoder(c.Request.Body).Decode(obj)
+	case formatXML:
+		err = xml.NewDecoder(c.Request.Body).Decode(obj)
+	case formatMsgPack:
+		err = codec.NewDecoder(c.Request.Body, new(codec.MsgpackHandle)).Decode(obj)
+	default:
+		return 415, errUnsupportedMediaType
+	}
+	if err == io.EOF {
+		return 400, errEmptyBody
+	}
+	if err != nil {
+		return 400, err
+	}
+	return 0, nil
+}
+
+// bind decodes the request body like decodeBody and validates the result
+// using the binding tags.
+func bind(c *gin.Context, obj interface{}) (int, error) {
+	if status, err := decodeBody(c, obj); err != nil {
+		return status, err
+	}
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return 400, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		method     string
+		path       string
+		accept     string
+		wantStatus int
+		wantFormat string
+	}{
+		{"GET", "/api/v1/persons", "text/csv", 200, formatCSV},
+		{"GET", "/api/v1/persons", "application/xml", 200, formatXML},
+		{"POST", "/api/v1/persons", "text/csv", 406, ""},
+		{"GET", "/api/v1/persons/1", "text/csv", 406, ""},
+		{"GET", "/api/v1/persons/1", "text/csv, application/json;q=0.5", 200, formatJSON},
+		{"GET", "/api/v1/persons/1", "application/x-msgpack", 200, formatMsgPack},
+		{"GET", "/api/v1/persons/1", "image/png", 406, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path+" "+tt.accept, func(t *testing.T) {
+			var format string
+			called := false
+			handle := func(c *gin.Context) {
+				called = true
+				format = c.GetString(formatKey)
+				c.Status(200)
+			}
+			r := gin.New()
+			persons := r.Group("/api/v1/persons", negotiateFormat)
+			persons.GET("", handle)
+			persons.POST("", handle)
+			persons.GET("/:id", handle)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler called = %v", called)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAgeBuckets bounds the number of histogram edges a client may ask for.
+const maxAgeBuckets = 50
+
+// @Summary Get person statistics
+// @Description Counts by gender and nationality, an age histogram, mean and median age and enrichment coverage of the persons matching the list filters
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param name query string false "Filter by name"
+// @Param surname query string false "Filter by surname"
+// @Param age query int false "Filter by age"
+// @Param gender query string false "Filter by gender" enum(male,female,other)
+// @Param nationality query string false "Filter by nationality"
+// @Param created_since query string false "Created at or after (RFC 3339)"
+// @Param created_before query string false "Created before (RFC 3339)"
+// @Param updated_since query string false "Updated at or after (RFC 3339)"
+// @Param updated_before query string false "Updated before (RFC 3339)"
+// @Param include_deleted query bool false "Include soft-deleted persons (admin only)"
+// @Param X-Admin-Token header string false "Admin token, required for include_deleted"
+// @Param age_buckets query string false "Ascending comma-separated histogram edges, decades from 0 to 100 by default"
+// @Success 200 {object} model.PersonStats
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/stats [get]
+func (h *Handler) getStats(c *gin.Context) {
+	filters, _, ok := h.listQuery(c)
+	if !ok {
+		return
+	}
+
+	buckets := model.DefaultAgeBuckets
+	if value := c.Query("age_buckets"); value != "" {
+		var err error
+		if buckets, err = parseAgeBuckets(value); err != nil {
+			h.logger(c).Debug("Invalid age_buckets: ", value)
+			h.render(c, 400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	stats, err := h.service.Stats(c.Request.Context(), filters, buckets)
+	if err != nil {
+		h.logger(c).Errorf("Failed to get stats: %v", err)
+		h.render(c, 500, gin.H{"error": err.Error()})
+		return
+	}
+	h.render(c, 200, stats)
+}
+
+// parseAgeBuckets parses comma-separated, strictly ascending, non-negative
+// histogram edges.
+func parseAgeBuckets(value string) ([]int, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > maxAgeBuckets {
+		return nil, errors.New("age_buckets allows at most 50 edges")
+	}
+
+	edges := make([]int, len(parts))
+	for i, part := range parts {
+		edge, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || edge < 0 {
+			return nil, errors.New("age_buckets must be non-negative integers")
+		}
+		if i > 0 && edge <= edges[i-1] {
+			return nil, errors.New("age_buckets must be strictly ascending")
+		}
+		edges[i] = edge
+	}
+	return edges, nil
+}
+
+// parseTimeZone loads an IANA time zone. "Local" is rejected: it names the
+// server's zone, which PostgreSQL does not know.
+func parseTimeZone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, errors.New("unknown time zone Local")
+	}
+	return time.LoadLocation(name)
+}
+
+// maxTimeSeriesPoints bounds the number of intervals a time series may span.
+const maxTimeSeriesPoints = 1000
+
+// defaultTimeSeriesPoints is how many intervals back from now a time series
+// reaches when from is not given.
+const defaultTimeSeriesPoints = 30
+
+// @Summary Get a time series of person registrations
+// @Description Number of persons created per day, week or month, zero-filled, truncated in the given time zone and optionally split by gender or nationality. Honors the list filters.
+// @Tags persons
+// @Produce json,xml,application/msgpack
+// @Param interval query string false "day, week or month" default(day)
+// @Param from query string false "Start of the range (RFC 3339), 30 intervals before to by default"
+// @Param to query string false "End of the range, exclusive (RFC 3339), now by default"
+// @Param tz query string false "IANA time zone the intervals are truncated in" default(UTC)
+// @Param group_by query string false "gender or nationality"
+// @Param name query string false "Filter by name"
+// @Param surname query string false "Filter by surname"
+// @Param age query int false "Filter by age"
+// @Param gender query string false "Filter by gender" enum(male,female,other)
+// @Param nationality query string false "Filter by nationality"
+// @Param include_deleted query bool false "Include soft-deleted persons (admin only)"
+// @Param X-Admin-Token header string false "Admin token, required for include_deleted"
+// @Success 200 {object} model.TimeSeries
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/persons/stats/timeseries [get]
+func (h *Handler) getTimeSeries(c *gin.Context) {
+	filters, _, ok := h.listQuery(c)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", model.IntervalDay)
+	if interval != model.IntervalDay && interval != model.IntervalWeek && interval != model.IntervalMonth {
+		h.render(c, 400, gin.H{"error": "interval must be day, week or month"})
+		return
+	}
+
+	loc, err := parseTimeZone(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		h.logger(c).Debug("Invalid tz: ", c.Query("tz"))
+		h.render(c, 400, gin.H{"error": "Invalid tz"})
+		return
+	}
+
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !model.TimeSeriesGroupFields[groupBy] {
+		h.render(c, 400, gin.H{"error": "group_by must be gender or nationality"})
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			h.render(c, 400, gin.H{"error": "Invalid to"})
+			return
+		}
+	}
+	var from time.Time
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			h.render(c, 400, gin.H{"error": "Invalid from"})
+			return
+		}
+	} else {
+		from = model.TruncateTime(to.In(loc), interval)
+		for i := 1; i < defaultTimeSeriesPoints; i++ {
+			from = model.TruncateTime(from.Add(-time.Nanosecond), interval)
+		}
+	}
+	if !from.Before(to) {
+		h.render(c, 400, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	points := 0
+	for start := model.TruncateTime(from.In(loc), interval); start.Before(to); start = model.NextInterval(start, interval) {
+		if points++; points > maxTimeSeriesPoints {
+			h.render(c, 400, gin.H{"error": fmt.Sprintf("Range spans more than %d intervals", maxTimeSeriesPoints)})
+			return
+		}
+	}
+
+	series, err := h.service.TimeSeries(c.Request.Context(), filters, interval, loc, groupBy, from, to)
+	if err != nil {
+		h.logger(c).Errorf("Failed to get time series: %v", err)
+		h.render(c, 500, gin.H{"error": err.Error()})
+		return
+	}
+	h.render(c, 200, series)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAgeBuckets(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{value: "18", want: []int{18}},
+		{value: "0,18,30,65", want: []int{0, 18, 30, 65}},
+		{value: " 18 , 30 ", want: []int{18, 30}},
+		{value: "", wantErr: true},
+		{value: "18,", wantErr: true},
+		{value: "-1,18", wantErr: true},
+		{value: "18,x", wantErr: true},
+		{value: "30,18", wantErr: true},
+		{value: "18,18", wantErr: true},
+		{value: strings.Repeat("1,", maxAgeBuckets) + "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseAgeBuckets(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAgeBuckets(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseAgeBuckets(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "UTC", want: "UTC"},
+		{name: "Europe/Moscow", want: "Europe/Moscow"},
+		{name: "Local", wantErr: true},
+		{name: "Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := parseTimeZone(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeZone(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+			if err == nil && loc.String() != tt.want {
+				t.Errorf("parseTimeZone(%q) = %v, want %v", tt.name, loc, tt.want)
+			}
+		})
+	}
+}
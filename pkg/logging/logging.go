@@ -0,0 +1,82 @@
+// Package logging builds the service's logger. Entries created with
+// WithContext carry the request id and trace id of the context, and fields
+// holding personal data are redacted unless the policy allows them.
+package logging
+
+import (
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/requestctx"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of personal data fields.
+const Redacted = "[redacted]"
+
+// PersonalFields are the log fields that may hold personal data. Names and
+// the like must only ever be logged under one of these keys, never inside
+// the message.
+var PersonalFields = []string{"name", "surname", "patronymic", "names"}
+
+// New returns a logger with the configured format, level and redaction.
+func New(cfg *config.Config) *logrus.Logger {
+	log := logrus.New()
+	if cfg.LogFormat == config.LogFormatText {
+		log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		log.SetFormatter(&logrus.JSONFormatter{})
+	}
+	Apply(log, cfg)
+	return log
+}
+
+// Apply sets log's level and redaction from cfg, so a reloaded
+// configuration takes effect on a running logger. The format is fixed by
+// New.
+func Apply(log *logrus.Logger, cfg *config.Config) {
+	level, _ := logrus.ParseLevel(cfg.LogLevel)
+	log.SetLevel(level)
+
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(contextHook{})
+	if cfg.LogRedactPersonalData {
+		hooks.Add(redactHook{})
+	}
+	log.ReplaceHooks(hooks)
+}
+
+// contextHook adds the request id and trace id of the entry's context.
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if requestID := requestctx.RequestID(entry.Context); requestID != "" {
+		entry.Data["request_id"] = requestID
+	}
+	if span := trace.SpanContextFromContext(entry.Context); span.HasTraceID() {
+		entry.Data["trace_id"] = span.TraceID().String()
+	}
+	return nil
+}
+
+// redactHook masks PersonalFields.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	for _, field := range PersonalFields {
+		if _, ok := entry.Data[field]; ok {
+			entry.Data[field] = Redacted
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package metrics defines the Prometheus metrics the service exports on
+// /metrics. They are registered with the default registry, which also
+// carries the Go runtime and process collectors.
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "person_service"
+
+var (
+	// HTTPRequests counts requests per route template, method and status.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// DBQueryDuration times repository methods, transactions and retries
+	// included; outcome is ok or error.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Repository method latency, by method and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "outcome"})
+
+	// EnrichRequests counts calls to the enrichment providers; outcome is
+	// ok, error or circuit_open.
+	EnrichRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "enrich",
+		Name:      "requests_total",
+		Help:      "Enrichment provider calls, by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	EnrichRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "enrich",
+		Name:      "request_duration_seconds",
+		Help:      "Enrichment provider latency, by provider.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	// EnrichCacheLookups counts enrichment cache lookups per name; result is
+	// hit or miss. Nothing is counted while the cache is disabled.
+	EnrichCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "enrich",
+		Name:      "cache_lookups_total",
+		Help:      "Enrichment cache lookups, by provider and result.",
+	}, []string{"provider", "result"})
+)
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "persons"))
+}
+
+// Outcome labels an operation by its error.
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"encoding/xml"
+	"sort"
+	"time"
+)
+
+type AuditOperation string
+
+const (
+	AuditCreate  AuditOperation = "create"
+	AuditEnrich  AuditOperation = "enrich"
+	AuditUpdate  AuditOperation = "update"
+	AuditPatch   AuditOperation = "patch"
+	AuditDelete  AuditOperation = "delete"
+	AuditRestore AuditOperation = "restore"
+	AuditPurge   AuditOperation = "purge"
+	AuditRevert  AuditOperation = "revert"
+	AuditMerge   AuditOperation = "merge"
+	// AuditBaseline records the state of a person that predates the audit
+	// log, as found when the log was backfilled.
+	AuditBaseline AuditOperation = "baseline"
+)
+
+// EnrichedFields are the person fields filled in from the external APIs
+// rather than supplied by the client.
+var EnrichedFields = map[string]bool{
+	"age":         true,
+	"gender":      true,
+	"nationality": true,
+}
+
+type FieldChange struct {
+	Old interface{} `json:"old" xml:"old"`
+	New interface{} `json:"new" xml:"new"`
+}
+
+// FieldChanges maps field names to their change.
+type FieldChanges map[string]FieldChange
+
+// MarshalXML renders the changes as <change field="..."> elements in field
+// order, since encoding/xml cannot marshal maps.
+func (c FieldChanges) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	fields := make([]string, 0, len(c))
+	for field := range c {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		change := xml.StartElement{
+			Name: xml.Name{Local: "change"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "field"}, Value: field}},
+		}
+		if err := e.EncodeElement(c[field], change); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+type AuditEntry struct {
+	ID        int64          `json:"id" xml:"id"`
+	PersonID  int64          `json:"person_id" xml:"person_id"`
+	Operation AuditOperation `json:"operation" xml:"operation"`
+	Actor     *string        `json:"actor,omitempty" xml:"actor,omitempty"`
+	RequestID *string        `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	Version   int64          `json:"version" xml:"version"`
+	Changes   FieldChanges   `json:"changes" xml:"changes"`
+	ChangedAt time.Time      `json:"changed_at" xml:"changed_at"`
+}
+
+// auditedFields lists, in order, the person fields tracked by the audit trail.
+var auditedFields = []string{"name", "surname", "patronymic", "age", "gender", "nationality", "deleted_at"}
+
+// DiffPersons returns the field-level changes from before to after. Either
+// side may be nil, for a person that did not exist yet or no longer exists.
+func DiffPersons(before, after *Person) FieldChanges {
+	old, cur := auditValues(before), auditValues(after)
+	changes := make(FieldChanges)
+	for _, field := range auditedFields {
+		if old[field] != cur[field] {
+			changes[field] = FieldChange{Old: old[field], New: cur[field]}
+		}
+	}
+	return changes
+}
+
+func auditValues(p *Person) map[string]interface{} {
+	values := make(map[string]interface{}, len(auditedFields))
+	if p == nil {
+		return values
+	}
+
+	values["name"] = p.Name
+	values["surname"] = p.Surname
+	if p.Patronymic != nil {
+		values["patronymic"] = *p.Patronymic
+	}
+	if p.Age != nil {
+		values["age"] = *p.Age
+	}
+	if p.Gender != nil {
+		values["gender"] = *p.Gender
+	}
+	if p.Nationality != nil {
+		values["nationality"] = *p.Nationality
+	}
+	if p.DeletedAt != nil {
+		values["deleted_at"] = p.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return values
+}
@@ -0,0 +1,10 @@
+package model
+
+// BatchItemResult reports the outcome of one item of a batch request, using
+// the HTTP status the item would have received on its own.
+type BatchItemResult struct {
+	Index  int    `json:"index" xml:"index"`
+	Status int    `json:"status" xml:"status"`
+	ID     *int64 `json:"id,omitempty" xml:"id,omitempty"`
+	Error  string `json:"error,omitempty" xml:"error,omitempty"`
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+)
+
+// DuplicatePair is a pair of persons that likely describe the same human,
+// with Score between 0 and 1.
+type DuplicatePair struct {
+	Person    *Person `json:"person"`
+	Duplicate *Person `json:"duplicate"`
+	Score     float64 `json:"score"`
+}
+
+// MarshalXML renders the pair as a <duplicate score="..."> element holding
+// both persons, since their own element name is fixed to <person>.
+func (p DuplicatePair) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "duplicate"
+	return e.EncodeElement(struct {
+		Score   float64   `xml:"score,attr"`
+		Persons []*Person `xml:"person"`
+	}{p.Score, []*Person{p.Person, p.Duplicate}}, start)
+}
+
+// Weights of the signals DuplicateScore combines. Signals missing on either
+// side are left out rather than counted as a mismatch.
+const (
+	nameWeight        = 0.3
+	surnameWeight     = 0.35
+	patronymicWeight  = 0.15
+	ageWeight         = 0.1
+	nationalityWeight = 0.1
+)
+
+// DuplicateScore rates how likely a and b are the same person, from 0 to 1.
+// Names are compared after normalization with an edit-distance similarity;
+// ages within a year of each other count as a match, since they are
+// estimates.
+func DuplicateScore(a, b *Person) float64 {
+	var score, weight float64
+	add := func(w, similarity float64) {
+		score += w * similarity
+		weight += w
+	}
+
+	add(nameWeight, similarity(NormalizeName(a.Name), NormalizeName(b.Name)))
+	add(surnameWeight, similarity(NormalizeName(a.Surname), NormalizeName(b.Surname)))
+	if a.Patronymic != nil && b.Patronymic != nil {
+		pa, pb := NormalizeName(*a.Patronymic), NormalizeName(*b.Patronymic)
+		if pa != "" && pb != "" {
+			add(patronymicWeight, similarity(pa, pb))
+		}
+	}
+	if a.Age != nil && b.Age != nil {
+		diff := *a.Age - *b.Age
+		add(ageWeight, boolScore(diff >= -1 && diff <= 1))
+	}
+	if a.Nationality != nil && b.Nationality != nil {
+		add(nationalityWeight, boolScore(strings.EqualFold(*a.Nationality, *b.Nationality)))
+	}
+
+	return math.Round(score/weight*1000) / 1000
+}
+
+// NormalizeName lowercases s, collapses whitespace and folds ё into е.
+func NormalizeName(s string) string {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	return strings.ReplaceAll(s, "ё", "е")
+}
+
+// similarity is one minus the Levenshtein distance of a and b relative to the
+// longer of the two.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func boolScore(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package model
+
+import "testing"
+
+func TestDuplicateScore(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+
+	tests := []struct {
+		name string
+		a, b Person
+		want float64
+	}{
+		{
+			name: "identical",
+			a:    Person{Name: "Ivan", Surname: "Ivanov", Patronymic: str("Petrovich"), Age: num(30), Nationality: str("RU")},
+			b:    Person{Name: "Ivan", Surname: "Ivanov", Patronymic: str("Petrovich"), Age: num(30), Nationality: str("RU")},
+			want: 1,
+		},
+		{
+			name: "case and whitespace are normalized",
+			a:    Person{Name: "Иван", Surname: "Иванов"},
+			b:    Person{Name: " иван ", Surname: "ИВАНОВ"},
+			want: 1,
+		},
+		{
+			name: "yo folds into ye",
+			a:    Person{Name: "Фёдор", Surname: "Орлов"},
+			b:    Person{Name: "Федор", Surname: "Орлов"},
+			want: 1,
+		},
+		{
+			name: "one letter off in the surname",
+			a:    Person{Name: "Ivan", Surname: "Ivanov"},
+			b:    Person{Name: "Ivan", Surname: "Ivanova"},
+			want: 0.923,
+		},
+		{
+			name: "different name",
+			a:    Person{Name: "Ivan", Surname: "Petrov"},
+			b:    Person{Name: "Oleg", Surname: "Petrov"},
+			want: 0.538,
+		},
+		{
+			name: "patronymic missing on one side is left out",
+			a:    Person{Name: "Ivan", Surname: "Ivanov", Patronymic: str("Petrovich")},
+			b:    Person{Name: "Ivan", Surname: "Ivanov"},
+			want: 1,
+		},
+		{
+			name: "ages a year apart match",
+			a:    Person{Name: "Ivan", Surname: "Ivanov", Age: num(30)},
+			b:    Person{Name: "Ivan", Surname: "Ivanov", Age: num(31)},
+			want: 1,
+		},
+		{
+			name: "ages further apart do not",
+			a:    Person{Name: "Ivan", Surname: "Ivanov", Age: num(30)},
+			b:    Person{Name: "Ivan", Surname: "Ivanov", Age: num(33)},
+			want: 0.867,
+		},
+		{
+			name: "nationality ignores case",
+			a:    Person{Name: "Ivan", Surname: "Ivanov", Nationality: str("RU")},
+			b:    Person{Name: "Ivan", Surname: "Ivanov", Nationality: str("ru")},
+			want: 1,
+		},
+		{
+			name: "different nationality",
+			a:    Person{Name: "Ivan", Surname: "Ivanov", Nationality: str("RU")},
+			b:    Person{Name: "Ivan", Surname: "Ivanov", Nationality: str("UA")},
+			want: 0.867,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DuplicateScore(&tt.a, &tt.b); got != tt.want {
+				t.Errorf("DuplicateScore = %v, want %v", got, tt.want)
+			}
+			if got := DuplicateScore(&tt.b, &tt.a); got != tt.want {
+				t.Errorf("DuplicateScore reversed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
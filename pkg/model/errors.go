@@ -0,0 +1,32 @@
+package model
+
+import "errors"
+
+var (
+	ErrInvalidInput    = errors.New("invalid input")
+	ErrNotFound        = errors.New("person not found")
+	ErrVersionMismatch = errors.New("person version does not match")
+	ErrNotDeleted      = errors.New("person is not deleted")
+	ErrNoSnapshot      = errors.New("no recorded state of the person at that point")
+	ErrDuplicatePerson = errors.New("a person with the same name, surname and patronymic already exists")
+	ErrImportNotFound  = errors.New("import not found")
+
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyClaimLost   = errors.New("idempotency key was taken over by another request")
+)
+
+// DuplicatePersonError reports a write rejected by the uniqueness policy.
+// ExistingID is the person already holding the name, or zero if it is not
+// known, such as when two items of the same batch collide.
+type DuplicatePersonError struct {
+	ExistingID int64
+}
+
+func (e *DuplicatePersonError) Error() string {
+	return ErrDuplicatePerson.Error()
+}
+
+func (e *DuplicatePersonError) Unwrap() error {
+	return ErrDuplicatePerson
+}
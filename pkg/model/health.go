@@ -0,0 +1,29 @@
+package model
+
+// Health statuses, of single checks and of whole reports.
+const (
+	HealthUp = "up"
+	// HealthDegraded means a dependency that is not critical is down.
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+	// HealthDraining is reported by readiness once shutdown has begun.
+	HealthDraining = "draining"
+)
+
+// HealthCheck is the outcome of checking one dependency.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Critical checks fail readiness when they are down.
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	// Circuit is the state of the circuit breaker in front of an
+	// enrichment provider: closed, open or half_open.
+	Circuit string `json:"circuit,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
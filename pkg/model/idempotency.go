@@ -0,0 +1,9 @@
+package model
+
+// IdempotentResponse is the response stored for an Idempotency-Key and
+// replayed when the same request is repeated.
+type IdempotentResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+}
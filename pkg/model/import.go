@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	ImportFailed    ImportStatus = "failed"
+)
+
+type ImportRowError struct {
+	Row    int    `json:"row" xml:"row"`
+	Reason string `json:"reason" xml:"reason"`
+}
+
+// ImportJob tracks one spreadsheet import. Rows are numbered as in the
+// uploaded file, so the header is row 1 and the first record is row 2.
+type ImportJob struct {
+	XMLName    xml.Name         `json:"-" xml:"import"`
+	ID         string           `json:"id" xml:"id"`
+	Status     ImportStatus     `json:"status" xml:"status"`
+	DryRun     bool             `json:"dry_run" xml:"dry_run"`
+	TotalRows  int              `json:"total_rows" xml:"total_rows"`
+	Imported   int              `json:"imported" xml:"imported"`
+	Rejected   int              `json:"rejected" xml:"rejected"`
+	Error      string           `json:"error,omitempty" xml:"error,omitempty"`
+	ReportURL  string           `json:"report_url,omitempty" xml:"report_url,omitempty"`
+	CreatedAt  time.Time        `json:"created_at" xml:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty" xml:"finished_at,omitempty"`
+	Errors     []ImportRowError `json:"-" xml:"-"`
+}
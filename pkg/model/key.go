@@ -0,0 +1,13 @@
+package model
+
+import "strings"
+
+// NaturalKey identifies a person by case-insensitive name, surname and
+// patronymic, ignoring surrounding whitespace.
+func NaturalKey(name, surname string, patronymic *string) string {
+	key := strings.ToLower(strings.TrimSpace(name)) + "|" + strings.ToLower(strings.TrimSpace(surname)) + "|"
+	if patronymic != nil {
+		key += strings.ToLower(strings.TrimSpace(*patronymic))
+	}
+	return key
+}
@@ -0,0 +1,87 @@
+package model
+
+import "encoding/xml"
+
+type MergeStrategy string
+
+const (
+	// MergeFillMissing keeps the survivor's values and takes the duplicate's
+	// only where the survivor has none.
+	MergeFillMissing MergeStrategy = "fill_missing"
+	// MergePreferDuplicate takes every value the duplicate has.
+	MergePreferDuplicate MergeStrategy = "prefer_duplicate"
+	// MergePreferNewest takes the values of whichever person was updated
+	// last, filling gaps from the other.
+	MergePreferNewest MergeStrategy = "prefer_newest"
+)
+
+// Merge sources that pin a single field regardless of the strategy.
+const (
+	MergeFromSurvivor  = "survivor"
+	MergeFromDuplicate = "duplicate"
+)
+
+// MergeFields pins individual fields to the survivor or the duplicate.
+type MergeFields struct {
+	Name        *string `json:"name,omitempty" xml:"name,omitempty" binding:"omitempty,oneof=survivor duplicate"`
+	Surname     *string `json:"surname,omitempty" xml:"surname,omitempty" binding:"omitempty,oneof=survivor duplicate"`
+	Patronymic  *string `json:"patronymic,omitempty" xml:"patronymic,omitempty" binding:"omitempty,oneof=survivor duplicate"`
+	Age         *string `json:"age,omitempty" xml:"age,omitempty" binding:"omitempty,oneof=survivor duplicate"`
+	Gender      *string `json:"gender,omitempty" xml:"gender,omitempty" binding:"omitempty,oneof=survivor duplicate"`
+	Nationality *string `json:"nationality,omitempty" xml:"nationality,omitempty" binding:"omitempty,oneof=survivor duplicate"`
+}
+
+type MergeRequest struct {
+	XMLName     xml.Name      `json:"-" xml:"merge"`
+	DuplicateID int64         `json:"duplicate_id" xml:"duplicate_id" binding:"required"`
+	Strategy    MergeStrategy `json:"strategy,omitempty" xml:"strategy,omitempty" binding:"omitempty,oneof=fill_missing prefer_duplicate prefer_newest"`
+	Fields      MergeFields   `json:"fields" xml:"fields"`
+}
+
+// MergePersons returns survivor with the duplicate's fields folded in as
+// req asks. Neither argument is modified.
+func MergePersons(survivor, duplicate *Person, req *MergeRequest) *Person {
+	merged := *survivor
+	newer := duplicate.UpdatedAt.After(survivor.UpdatedAt)
+	take := func(pin *string, survivorEmpty, duplicateEmpty bool) bool {
+		return mergeFromDuplicate(req.Strategy, pin, survivorEmpty, duplicateEmpty, newer)
+	}
+
+	if take(req.Fields.Name, survivor.Name == "", duplicate.Name == "") {
+		merged.Name = duplicate.Name
+	}
+	if take(req.Fields.Surname, survivor.Surname == "", duplicate.Surname == "") {
+		merged.Surname = duplicate.Surname
+	}
+	if take(req.Fields.Patronymic, survivor.Patronymic == nil, duplicate.Patronymic == nil) {
+		merged.Patronymic = duplicate.Patronymic
+	}
+	if take(req.Fields.Age, survivor.Age == nil, duplicate.Age == nil) {
+		merged.Age = duplicate.Age
+	}
+	if take(req.Fields.Gender, survivor.Gender == nil, duplicate.Gender == nil) {
+		merged.Gender = duplicate.Gender
+	}
+	if take(req.Fields.Nationality, survivor.Nationality == nil, duplicate.Nationality == nil) {
+		merged.Nationality = duplicate.Nationality
+	}
+
+	return &merged
+}
+
+func mergeFromDuplicate(strategy MergeStrategy, pin *string, survivorEmpty, duplicateEmpty, duplicateNewer bool) bool {
+	if pin != nil {
+		return *pin == MergeFromDuplicate
+	}
+	switch strategy {
+	case MergePreferDuplicate:
+		return !duplicateEmpty
+	case MergePreferNewest:
+		if duplicateNewer {
+			return !duplicateEmpty
+		}
+		return survivorEmpty && !duplicateEmpty
+	default:
+		return survivorEmpty && !duplicateEmpty
+	}
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergePersons(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	now := time.Now()
+
+	survivor := &Person{ID: 1, Name: "Ivan", Surname: "Ivanov", Patronymic: str("Petrovich"), Age: num(30), UpdatedAt: now}
+	duplicate := &Person{ID: 2, Name: "Ivan", Surname: "Ivanova", Age: num(31), Gender: str("male"), Nationality: str("RU")}
+
+	type fields struct {
+		surname, patronymic, gender, nationality string
+		age                                      int
+	}
+	tests := []struct {
+		name           string
+		req            MergeRequest
+		duplicateNewer bool
+		want           fields
+	}{
+		{
+			name: "fill missing by default",
+			want: fields{surname: "Ivanov", patronymic: "Petrovich", age: 30, gender: "male", nationality: "RU"},
+		},
+		{
+			name: "prefer duplicate keeps survivor values the duplicate lacks",
+			req:  MergeRequest{Strategy: MergePreferDuplicate},
+			want: fields{surname: "Ivanova", patronymic: "Petrovich", age: 31, gender: "male", nationality: "RU"},
+		},
+		{
+			name:           "prefer newest with a newer duplicate",
+			req:            MergeRequest{Strategy: MergePreferNewest},
+			duplicateNewer: true,
+			want:           fields{surname: "Ivanova", patronymic: "Petrovich", age: 31, gender: "male", nationality: "RU"},
+		},
+		{
+			name: "prefer newest with a newer survivor",
+			req:  MergeRequest{Strategy: MergePreferNewest},
+			want: fields{surname: "Ivanov", patronymic: "Petrovich", age: 30, gender: "male", nationality: "RU"},
+		},
+		{
+			name: "field pinned to the survivor",
+			req:  MergeRequest{Strategy: MergePreferDuplicate, Fields: MergeFields{Age: str(MergeFromSurvivor)}},
+			want: fields{surname: "Ivanova", patronymic: "Petrovich", age: 30, gender: "male", nationality: "RU"},
+		},
+		{
+			name: "field pinned to the duplicate",
+			req:  MergeRequest{Fields: MergeFields{Surname: str(MergeFromDuplicate)}},
+			want: fields{surname: "Ivanova", patronymic: "Petrovich", age: 30, gender: "male", nationality: "RU"},
+		},
+		{
+			name: "field pinned to an empty duplicate value",
+			req:  MergeRequest{Fields: MergeFields{Patronymic: str(MergeFromDuplicate)}},
+			want: fields{surname: "Ivanov", age: 30, gender: "male", nationality: "RU"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dup := *duplicate
+			dup.UpdatedAt = now.Add(-time.Hour)
+			if tt.duplicateNewer {
+				dup.UpdatedAt = now.Add(time.Hour)
+			}
+
+			merged := MergePersons(survivor, &dup, &tt.req)
+
+			got := fields{surname: merged.Surname, age: *merged.Age}
+			if merged.Patronymic != nil {
+				got.patronymic = *merged.Patronymic
+			}
+			if merged.Gender != nil {
+				got.gender = *merged.Gender
+			}
+			if merged.Nationality != nil {
+				got.nationality = *merged.Nationality
+			}
+			if got != tt.want {
+				t.Errorf("MergePersons = %+v, want %+v", got, tt.want)
+			}
+			if merged.ID != survivor.ID {
+				t.Errorf("merged ID = %d, want the survivor's %d", merged.ID, survivor.ID)
+			}
+			if survivor.Surname != "Ivanov" || *survivor.Age != 30 || survivor.Gender != nil {
+				t.Errorf("survivor was modified: %+v", survivor)
+			}
+		})
+	}
+}
@@ -1,26 +1,36 @@
 package model
 
+import (
+	"encoding/xml"
+	"time"
+)
+
 type Person struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Surname     string  `json:"surname"`
-	Patronymic  *string `json:"patronymic,omitempty"`
-	Age         *int    `json:"age,omitempty"`
-	Gender      *string `json:"gender,omitempty" binding:"omitempty,oneof=male female other"`
-	Nationality *string `json:"nationality,omitempty"`
+	XMLName     xml.Name   `json:"-" xml:"person"`
+	ID          int64      `json:"id" xml:"id"`
+	Name        string     `json:"name" xml:"name"`
+	Surname     string     `json:"surname" xml:"surname"`
+	Patronymic  *string    `json:"patronymic,omitempty" xml:"patronymic,omitempty"`
+	Age         *int       `json:"age,omitempty" xml:"age,omitempty"`
+	Gender      *string    `json:"gender,omitempty" xml:"gender,omitempty" binding:"omitempty,oneof=male female other"`
+	Nationality *string    `json:"nationality,omitempty" xml:"nationality,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" xml:"updated_at"`
+	Version     int64      `json:"version" xml:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
 }
 
 type PersonRequest struct {
-	Name       string  `json:"name" binding:"required"`
-	Surname    string  `json:"surname" binding:"required"`
-	Patronymic *string `json:"patronymic,omitempty"`
+	Name       string  `json:"name" xml:"name" binding:"required"`
+	Surname    string  `json:"surname" xml:"surname" binding:"required"`
+	Patronymic *string `json:"patronymic,omitempty" xml:"patronymic,omitempty"`
 }
 
 type PersonPatchRequest struct {
-	Name        *string `json:"name,omitempty"`
-	Surname     *string `json:"surname,omitempty"`
-	Patronymic  *string `json:"patronymic,omitempty"`
-	Age         *int    `json:"age,omitempty"`
-	Gender      *string `json:"gender,omitempty" binding:"omitempty,oneof=male female other"`
-	Nationality *string `json:"nationality,omitempty"`
+	Name        *string `json:"name,omitempty" xml:"name,omitempty"`
+	Surname     *string `json:"surname,omitempty" xml:"surname,omitempty"`
+	Patronymic  *string `json:"patronymic,omitempty" xml:"patronymic,omitempty"`
+	Age         *int    `json:"age,omitempty" xml:"age,omitempty"`
+	Gender      *string `json:"gender,omitempty" xml:"gender,omitempty" binding:"omitempty,oneof=male female other"`
+	Nationality *string `json:"nationality,omitempty" xml:"nationality,omitempty"`
 }
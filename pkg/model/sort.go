@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortFields lists the person fields that list queries can be ordered by.
+var SortFields = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"surname":    true,
+	"age":        true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort expression such as "updated_at" or "-created_at",
+// where a leading minus selects descending order. An empty expression sorts by id.
+func ParseSort(expr string) (Sort, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Sort{Field: "id"}, nil
+	}
+
+	sort := Sort{Field: expr}
+	if strings.HasPrefix(expr, "-") {
+		sort = Sort{Field: expr[1:], Desc: true}
+	}
+	if !SortFields[sort.Field] {
+		return Sort{}, fmt.Errorf("unsupported sort field: %s", sort.Field)
+	}
+	return sort, nil
+}
@@ -0,0 +1,33 @@
+package model
+
+import "testing"
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    Sort
+		wantErr bool
+	}{
+		{expr: "", want: Sort{Field: "id"}},
+		{expr: "  ", want: Sort{Field: "id"}},
+		{expr: "surname", want: Sort{Field: "surname"}},
+		{expr: "-created_at", want: Sort{Field: "created_at", Desc: true}},
+		{expr: " updated_at ", want: Sort{Field: "updated_at"}},
+		{expr: "-", wantErr: true},
+		{expr: "--age", wantErr: true},
+		{expr: "+age", wantErr: true},
+		{expr: "nationality", wantErr: true},
+		{expr: "Surname", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseSort(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSort(%q) error = %v, want error %v", tt.expr, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSort(%q) = %+v, want %+v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
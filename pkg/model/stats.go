@@ -0,0 +1,47 @@
+package model
+
+import "encoding/xml"
+
+// DefaultAgeBuckets are the age histogram edges used when none are given:
+// decades from 0 to 100.
+var DefaultAgeBuckets = []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+
+// ValueCount is how many persons have Value, where nil counts persons
+// without one.
+type ValueCount struct {
+	Value *string `json:"value" xml:"value,omitempty"`
+	Count int64   `json:"count" xml:"count"`
+}
+
+// AgeBucket counts persons aged at least From and below To. The first and
+// last buckets of a histogram are open-ended.
+type AgeBucket struct {
+	From  *int  `json:"from,omitempty" xml:"from,omitempty"`
+	To    *int  `json:"to,omitempty" xml:"to,omitempty"`
+	Count int64 `json:"count" xml:"count"`
+}
+
+type AgeSummary struct {
+	Mean   *float64 `json:"mean" xml:"mean,omitempty"`
+	Median *float64 `json:"median" xml:"median,omitempty"`
+	Min    *int     `json:"min" xml:"min,omitempty"`
+	Max    *int     `json:"max" xml:"max,omitempty"`
+}
+
+// EnrichmentCoverage is the percentage of persons that have each enriched
+// field.
+type EnrichmentCoverage struct {
+	Age         float64 `json:"age" xml:"age"`
+	Gender      float64 `json:"gender" xml:"gender"`
+	Nationality float64 `json:"nationality" xml:"nationality"`
+}
+
+type PersonStats struct {
+	XMLName       xml.Name           `json:"-" xml:"stats"`
+	Total         int64              `json:"total" xml:"total"`
+	ByGender      []ValueCount       `json:"by_gender" xml:"by_gender>gender"`
+	ByNationality []ValueCount       `json:"by_nationality" xml:"by_nationality>nationality"`
+	AgeHistogram  []AgeBucket        `json:"age_histogram" xml:"age_histogram>bucket"`
+	Age           AgeSummary         `json:"age" xml:"age"`
+	Coverage      EnrichmentCoverage `json:"coverage" xml:"coverage"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Time series intervals, named after the date_trunc fields they map to.
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// TimeSeriesGroupFields are the person fields a time series can be split by.
+var TimeSeriesGroupFields = map[string]bool{
+	"gender":      true,
+	"nationality": true,
+}
+
+// BucketCount is the number of persons created in the bucket starting at
+// Start, a wall-clock time in the series' time zone, with Value in the
+// grouped field.
+type BucketCount struct {
+	Start time.Time
+	Value *string
+	Count int64
+}
+
+type TimeSeriesPoint struct {
+	Start time.Time `json:"start" xml:"start,attr"`
+	Count int64     `json:"count" xml:",chardata"`
+}
+
+// TimeSeriesGroup is the series for one value of the grouped field, or for
+// all persons when the series is not grouped.
+type TimeSeriesGroup struct {
+	Value  *string           `json:"value,omitempty" xml:"value,attr,omitempty"`
+	Points []TimeSeriesPoint `json:"points" xml:"point"`
+}
+
+type TimeSeries struct {
+	XMLName  xml.Name          `json:"-" xml:"timeseries"`
+	Interval string            `json:"interval" xml:"interval,attr"`
+	TimeZone string            `json:"time_zone" xml:"time_zone,attr"`
+	GroupBy  string            `json:"group_by,omitempty" xml:"group_by,attr,omitempty"`
+	From     time.Time         `json:"from" xml:"from,attr"`
+	To       time.Time         `json:"to" xml:"to,attr"`
+	Groups   []TimeSeriesGroup `json:"groups" xml:"group"`
+}
+
+// TruncateTime returns the start of the interval containing t in t's
+// location. Weeks start on Monday, as with PostgreSQL's date_trunc.
+func TruncateTime(t time.Time, interval string) time.Time {
+	year, month, day := t.Date()
+	switch interval {
+	case IntervalWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case IntervalMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// NextInterval returns the start of the interval after the one starting at t.
+func NextInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTruncateTime(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name     string
+		t        time.Time
+		interval string
+		want     time.Time
+		next     time.Time
+	}{
+		{
+			name:     "day",
+			t:        time.Date(2026, 3, 18, 15, 4, 5, 6, time.UTC),
+			interval: IntervalDay,
+			want:     time.Date(2026, 3, 18, 0, 0, 0, 0, time.UTC),
+			next:     time.Date(2026, 3, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day in the time's location",
+			t:        time.Date(2026, 3, 18, 1, 0, 0, 0, moscow),
+			interval: IntervalDay,
+			want:     time.Date(2026, 3, 18, 0, 0, 0, 0, moscow),
+			next:     time.Date(2026, 3, 19, 0, 0, 0, 0, moscow),
+		},
+		{
+			name:     "week from a Wednesday",
+			t:        time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC),
+			interval: IntervalWeek,
+			want:     time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+			next:     time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "week from a Sunday",
+			t:        time.Date(2026, 3, 22, 23, 0, 0, 0, time.UTC),
+			interval: IntervalWeek,
+			want:     time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+			next:     time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "week across a month boundary",
+			t:        time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			interval: IntervalWeek,
+			want:     time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC),
+			next:     time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "month",
+			t:        time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC),
+			interval: IntervalMonth,
+			want:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			next:     time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateTime(tt.t, tt.interval)
+			if !got.Equal(tt.want) || got.Location() != tt.want.Location() {
+				t.Errorf("TruncateTime = %v, want %v", got, tt.want)
+			}
+			if next := NextInterval(got, tt.interval); !next.Equal(tt.next) {
+				t.Errorf("NextInterval = %v, want %v", next, tt.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package repository defines the storage interface of the service. It sits
+// apart from both the service, which uses it, and the database package,
+// which implements it, so that neither has to import the other.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// Repository stores persons, their history, idempotency keys and import
+// jobs.
+type Repository interface {
+	// WithTx runs fn with a Repository bound to a single transaction; see
+	// database.Repository.WithTx.
+	WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx Repository) error) error
+	Create(ctx context.Context, person *model.Person) (int64, error)
+	CreateBatch(ctx context.Context, persons []*model.Person) error
+	ExistingKeys(ctx context.Context, keys []string) (map[string]bool, error)
+	Export(ctx context.Context, filters map[string]string, sort model.Sort, fn func(*model.Person) error) error
+	GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.Person, error)
+	GetByNaturalKey(ctx context.Context, key string) (*model.Person, error)
+	GetAll(ctx context.Context, page, limit int, filters map[string]string, sort model.Sort) ([]*model.Person, error)
+	Stats(ctx context.Context, filters map[string]string, ageBuckets []int) (*model.PersonStats, error)
+	CountCreated(ctx context.Context, filters map[string]string, interval, timeZone, groupBy string, from, to time.Time) ([]model.BucketCount, error)
+	Update(ctx context.Context, person *model.Person, expectedVersion int64) error
+	Upsert(ctx context.Context, person *model.Person, expectedVersion int64) (bool, error)
+	Patch(ctx context.Context, id, expectedVersion int64, patch *model.PersonPatchRequest) (*model.Person, error)
+	Delete(ctx context.Context, id, expectedVersion int64) error
+	Restore(ctx context.Context, id, expectedVersion int64) (*model.Person, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	History(ctx context.Context, personID int64, page, limit int) ([]*model.AuditEntry, error)
+	GetAsOf(ctx context.Context, id int64, asOf time.Time) (*model.Person, error)
+	Revert(ctx context.Context, id, version, expectedVersion int64) (*model.Person, error)
+	DuplicateCandidates(ctx context.Context, personID int64, neighbors, limit int) ([][2]*model.Person, bool, error)
+	Merge(ctx context.Context, survivorID, expectedVersion int64, req *model.MergeRequest) (*model.Person, error)
+	ClaimIdempotencyKey(ctx context.Context, key, token, requestHash string, expiresAt, staleBefore time.Time) (*model.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, key, token string, resp *model.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, key, token string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	CreateImportJob(ctx context.Context, job *model.ImportJob) error
+	UpdateImportJob(ctx context.Context, job *model.ImportJob) error
+	GetImportJob(ctx context.Context, id string) (*model.ImportJob, error)
+	ImportErrors(ctx context.Context, id string) ([]model.ImportRowError, error)
+	DeleteImportJobs(ctx context.Context, before time.Time) (int64, error)
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (uint, bool, error)
+}
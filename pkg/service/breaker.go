@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half_open"
+)
+
+var errCircuitOpen = errors.New("circuit open")
+
+// circuitBreaker stops calls to a provider after threshold consecutive
+// failures. Once cooldown has passed, a single trial call is let through:
+// its success closes the circuit again, its failure reopens it.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker returns a closed breaker; a threshold of zero or less
+// never opens it.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: circuitClosed}
+}
+
+// configure changes the thresholds of the breaker, keeping its state.
+func (b *circuitBreaker) configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+// allow reports whether a call may go ahead. Every allowed call must be
+// followed by done.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	}
+	return true
+}
+
+// done records the outcome of an allowed call. Calls cut short by their
+// caller's context say nothing about the provider and are not counted.
+func (b *circuitBreaker) done(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err == nil:
+		b.state = circuitClosed
+		b.failures = 0
+	case ctx.Err() != nil:
+		// Give the next call the trial this one did not finish.
+		if b.state == circuitHalfOpen {
+			b.state = circuitOpen
+		}
+	default:
+		b.failures++
+		if b.state == circuitHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+			b.state = circuitOpen
+			b.openedAt = time.Now()
+		}
+	}
+}
+
+func (b *circuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/metrics"
+)
+
+// resultCache remembers a provider's answers per name for a while, including
+// that it had none. A nil cache is disabled and remembers nothing.
+type resultCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	// value is nil when the provider knew nothing about the name.
+	value   interface{}
+	expires time.Time
+}
+
+// newResultCache returns a cache holding up to size names for ttl, or nil
+// when ttl is not positive.
+func newResultCache(ttl time.Duration, size int) *resultCache {
+	if ttl <= 0 || size <= 0 {
+		return nil
+	}
+	return &resultCache{ttl: ttl, size: size, entries: make(map[string]cacheEntry)}
+}
+
+// matches reports whether c is the cache newResultCache(ttl, size) would
+// return.
+func (c *resultCache) matches(ttl time.Duration, size int) bool {
+	if c == nil {
+		return newResultCache(ttl, size) == nil
+	}
+	return c.ttl == ttl && c.size == size
+}
+
+func (c *resultCache) get(name string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// put stores value for name. A full cache first drops expired entries and,
+// if that is not enough, an arbitrary one.
+func (c *resultCache) put(name string, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.entries[name]; !ok && len(c.entries) >= c.size {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		for key := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, key)
+		}
+	}
+	c.entries[name] = cacheEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// cachedLookup answers the names p's cache knows and asks fetch for the
+// rest, caching what it returns. When fetch fails, the cached answers are
+// returned along with the error.
+func cachedLookup[T any](ctx context.Context, p *provider, names []string, fetch func(ctx context.Context, names []string) (map[string]T, error)) (map[string]T, error) {
+	if p.cache == nil {
+		return fetch(ctx, names)
+	}
+
+	results := make(map[string]T, len(names))
+	var missing []string
+	for _, name := range names {
+		value, ok := p.cache.get(name)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if value != nil {
+			results[name] = value.(T)
+		}
+	}
+	metrics.EnrichCacheLookups.WithLabelValues(p.name, "hit").Add(float64(len(names) - len(missing)))
+	metrics.EnrichCacheLookups.WithLabelValues(p.name, "miss").Add(float64(len(missing)))
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	fetched, err := fetch(ctx, missing)
+	if err != nil {
+		return results, err
+	}
+	for _, name := range missing {
+		value, ok := fetched[name]
+		if !ok {
+			p.cache.put(name, nil)
+			continue
+		}
+		p.cache.put(name, value)
+		results[name] = value
+	}
+	return results, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+const (
+	// duplicateNeighbors is how many persons with the nearest surnames each
+	// person is compared with.
+	duplicateNeighbors = 20
+	// duplicateCandidateLimit caps the pairs pulled from the database for
+	// scoring, keeping a single duplicate scan bounded on large tables.
+	duplicateCandidateLimit = 5000
+)
+
+// FindDuplicates returns up to limit pairs of persons scoring at least
+// minScore, best first. A non-zero personID only looks for duplicates of that
+// person. truncated reports that the scan stopped at duplicateCandidateLimit
+// candidates, so some persons were not compared; asking for the duplicates
+// of one person at a time covers them.
+func (s *Service) FindDuplicates(ctx context.Context, personID int64, minScore float64, limit int) (_ []*model.DuplicatePair, truncated bool, err error) {
+	ctx, span := startSpan(ctx, "FindDuplicates")
+	defer endSpan(span, &err)
+
+	candidates, truncated, err := s.repo.DuplicateCandidates(ctx, personID, duplicateNeighbors, duplicateCandidateLimit)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to find duplicate candidates: %v", err)
+		return nil, false, err
+	}
+	if truncated {
+		s.log.WithContext(ctx).Warnf("Duplicate scan stopped after %d candidates", len(candidates))
+	}
+
+	pairs := make([]*model.DuplicatePair, 0)
+	for _, candidate := range candidates {
+		score := model.DuplicateScore(candidate[0], candidate[1])
+		if score < minScore {
+			continue
+		}
+		pairs = append(pairs, &model.DuplicatePair{Person: candidate[0], Duplicate: candidate[1], Score: score})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Score > pairs[j].Score
+	})
+	if len(pairs) > limit {
+		pairs = pairs[:limit]
+	}
+
+	s.log.WithContext(ctx).Infof("Found %d likely duplicates among %d candidates", len(pairs), len(candidates))
+	return pairs, truncated, nil
+}
+
+func (s *Service) Merge(ctx context.Context, survivorID, expectedVersion int64, req *model.MergeRequest) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "Merge")
+	defer endSpan(span, &err)
+
+	if req.DuplicateID == survivorID {
+		return nil, fmt.Errorf("%w: a person cannot be merged into itself", model.ErrInvalidInput)
+	}
+	if req.Strategy == "" {
+		req.Strategy = model.MergeFillMissing
+	}
+
+	person, err := s.repo.Merge(ctx, survivorID, expectedVersion, req)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to merge person %d into %d: %v", req.DuplicateID, survivorID, err)
+		return nil, err
+	}
+	s.log.WithContext(ctx).Infof("Merged person %d into %d", req.DuplicateID, survivorID)
+	return person, nil
+}
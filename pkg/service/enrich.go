@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/metrics"
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// enrichBatchSize is the most names agify, genderize and nationalize accept
+// in a single request.
+const enrichBatchSize = 10
+
+// Enrichment providers.
+const (
+	ProviderAgify       = "agify"
+	ProviderGenderize   = "genderize"
+	ProviderNationalize = "nationalize"
+)
+
+// providerNames lists the enrichment providers in reporting order.
+var providerNames = []string{ProviderAgify, ProviderGenderize, ProviderNationalize}
+
+// provider is an enrichment API, the circuit breaker guarding it and the
+// cache of its answers. key is the API key of a paid tier, if any.
+type provider struct {
+	name    string
+	url     string
+	key     string
+	breaker *circuitBreaker
+	cache   *resultCache
+	probe   *probeResult
+}
+
+// newProviders builds the providers cfg configures. Those of current, if
+// any, hand their breakers over with the state they are in, their last
+// health probes unless the provider's URL changed, and their caches too
+// unless the URL or the cache settings changed.
+func newProviders(cfg *config.Config, current map[string]*provider) map[string]*provider {
+	urls := map[string]string{
+		ProviderAgify:       cfg.APIAgifyURL,
+		ProviderGenderize:   cfg.APIGenderizeURL,
+		ProviderNationalize: cfg.APINationalizeURL,
+	}
+	keys := map[string]string{
+		ProviderAgify:       cfg.APIAgifyKey,
+		ProviderGenderize:   cfg.APIGenderizeKey,
+		ProviderNationalize: cfg.APINationalizeKey,
+	}
+	providers := make(map[string]*provider, len(urls))
+	for name, url := range urls {
+		p := &provider{name: name, url: url, key: keys[name]}
+		if old, ok := current[name]; ok {
+			p.breaker = old.breaker
+			p.breaker.configure(cfg.EnrichBreakerThreshold, cfg.EnrichBreakerCooldown)
+			if old.url == url {
+				p.probe = old.probe
+			}
+			if old.url == url && old.cache.matches(cfg.EnrichCacheTTL, cfg.EnrichCacheSize) {
+				p.cache = old.cache
+			}
+		} else {
+			p.breaker = newCircuitBreaker(cfg.EnrichBreakerThreshold, cfg.EnrichBreakerCooldown)
+		}
+		if p.cache == nil {
+			p.cache = newResultCache(cfg.EnrichCacheTTL, cfg.EnrichCacheSize)
+		}
+		if p.probe == nil {
+			p.probe = &probeResult{}
+		}
+		providers[name] = p
+	}
+	return providers
+}
+
+// provider returns the enrichment provider called name as currently
+// configured.
+func (s *Service) provider(name string) *provider {
+	return (*s.providers.Load())[name]
+}
+
+// enrichment is what the external APIs know about a name.
+type enrichment struct {
+	Age         *int
+	Gender      *string
+	Nationality *string
+}
+
+func (e enrichment) applyTo(person *model.Person) {
+	person.Age = e.Age
+	person.Gender = e.Gender
+	person.Nationality = e.Nationality
+}
+
+// fillMissing sets the fields person does not have yet.
+func (e enrichment) fillMissing(person *model.Person) {
+	if person.Age == nil {
+		person.Age = e.Age
+	}
+	if person.Gender == nil {
+		person.Gender = e.Gender
+	}
+	if person.Nationality == nil {
+		person.Nationality = e.Nationality
+	}
+}
+
+// nameKey normalizes a name for deduplicating lookups; the APIs themselves
+// are case-insensitive.
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (s *Service) enrichPerson(ctx context.Context, person *model.Person) {
+	s.enrich(ctx, []string{person.Name})[nameKey(person.Name)].applyTo(person)
+}
+
+// newPersons builds enriched persons from reqs, looking up each distinct
+// name once.
+func (s *Service) newPersons(ctx context.Context, reqs []model.PersonRequest) []*model.Person {
+	names := make([]string, len(reqs))
+	for i, req := range reqs {
+		names[i] = req.Name
+	}
+	enriched := s.enrich(ctx, names)
+
+	persons := make([]*model.Person, len(reqs))
+	for i, req := range reqs {
+		persons[i] = &model.Person{
+			Name:       req.Name,
+			Surname:    req.Surname,
+			Patronymic: req.Patronymic,
+		}
+		enriched[nameKey(req.Name)].applyTo(persons[i])
+	}
+	return persons
+}
+
+// enrich looks up age, gender and nationality for each distinct name, in
+// batches of enrichBatchSize. Lookups that fail leave the fields unset.
+func (s *Service) enrich(ctx context.Context, names []string) map[string]enrichment {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		key := nameKey(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, key)
+	}
+
+	results := make(map[string]enrichment, len(unique))
+	for start := 0; start < len(unique); start += enrichBatchSize {
+		end := start + enrichBatchSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+		chunk := unique[start:end]
+		log := s.log.WithContext(ctx).WithField("names", chunk)
+
+		ages, err := cachedLookup(ctx, s.provider(ProviderAgify), chunk, s.getAges)
+		if err != nil {
+			log.Debugf("Failed to get age: %v", err)
+		}
+		genders, err := cachedLookup(ctx, s.provider(ProviderGenderize), chunk, s.getGenders)
+		if err != nil {
+			log.Debugf("Failed to get gender: %v", err)
+		}
+		nationalities, err := cachedLookup(ctx, s.provider(ProviderNationalize), chunk, s.getNationalities)
+		if err != nil {
+			log.Debugf("Failed to get nationality: %v", err)
+		}
+
+		for _, key := range chunk {
+			var e enrichment
+			if age, ok := ages[key]; ok {
+				e.Age = &age
+			}
+			if gender, ok := genders[key]; ok {
+				e.Gender = &gender
+			}
+			if nationality, ok := nationalities[key]; ok {
+				e.Nationality = &nationality
+			}
+			results[key] = e
+		}
+	}
+	return results
+}
+
+func (s *Service) getAges(ctx context.Context, names []string) (map[string]int, error) {
+	var result []struct {
+		Name string `json:"name"`
+		Age  *int   `json:"age"`
+	}
+	if err := s.lookup(ctx, s.provider(ProviderAgify), names, &result); err != nil {
+		return nil, err
+	}
+
+	ages := make(map[string]int, len(result))
+	for _, r := range result {
+		if r.Age != nil {
+			ages[nameKey(r.Name)] = *r.Age
+		}
+	}
+	return ages, nil
+}
+
+func (s *Service) getGenders(ctx context.Context, names []string) (map[string]string, error) {
+	var result []struct {
+		Name   string `json:"name"`
+		Gender string `json:"gender"`
+	}
+	if err := s.lookup(ctx, s.provider(ProviderGenderize), names, &result); err != nil {
+		return nil, err
+	}
+
+	genders := make(map[string]string, len(result))
+	for _, r := range result {
+		gender := r.Gender
+		if gender != "male" && gender != "female" {
+			gender = "other"
+		}
+		genders[nameKey(r.Name)] = gender
+	}
+	return genders, nil
+}
+
+func (s *Service) getNationalities(ctx context.Context, names []string) (map[string]string, error) {
+	var result []struct {
+		Name    string `json:"name"`
+		Country []struct {
+			CountryID   string  `json:"country_id"`
+			Probability float64 `json:"probability"`
+		} `json:"country"`
+	}
+	if err := s.lookup(ctx, s.provider(ProviderNationalize), names, &result); err != nil {
+		return nil, err
+	}
+
+	nationalities := make(map[string]string, len(result))
+	for _, r := range result {
+		maxProb := 0.0
+		var maxCountry string
+		for _, country := range r.Country {
+			if country.Probability > maxProb {
+				maxProb = country.Probability
+				maxCountry = country.CountryID
+			}
+		}
+		if maxCountry != "" {
+			nationalities[nameKey(r.Name)] = maxCountry
+		}
+	}
+	return nationalities, nil
+}
+
+// lookup queries an enrichment API for several names at once using the
+// name[] parameter and decodes the JSON array it returns into out. While the
+// provider's circuit is open it fails without calling the API.
+func (s *Service) lookup(ctx context.Context, p *provider, names []string, out interface{}) (err error) {
+	ctx, span := tracer.Start(ctx, "enrich."+p.name, trace.WithAttributes(
+		attribute.String("enrich.provider", p.name),
+		attribute.Int("enrich.names", len(names)),
+	))
+	defer endSpan(span, &err)
+
+	if !p.breaker.allow() {
+		metrics.EnrichRequests.WithLabelValues(p.name, "circuit_open").Inc()
+		return fmt.Errorf("%s: %w", p.name, errCircuitOpen)
+	}
+	start := time.Now()
+	err = s.fetch(ctx, p, names, out)
+	p.breaker.done(ctx, err)
+
+	metrics.EnrichRequestDuration.WithLabelValues(p.name).Observe(time.Since(start).Seconds())
+	metrics.EnrichRequests.WithLabelValues(p.name, metrics.Outcome(err)).Inc()
+	return err
+}
+
+func (s *Service) fetch(ctx context.Context, p *provider, names []string, out interface{}) error {
+	params := url.Values{}
+	for _, name := range names {
+		params.Add("name[]", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Current().EnrichTimeout)
+	defer cancel()
+	if p.key != "" {
+		ctx = context.WithValue(ctx, apiKeyContextKey{}, p.key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error quotes the URL, whose query holds the names.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = p.url
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type apiKeyContextKey struct{}
+
+// apiKeyTransport adds the API key fetch put in the request's context to
+// the query. It sits below the tracing transport, which records the URL it
+// is given, so the key stays out of traces.
+type apiKeyTransport struct {
+	base http.RoundTripper
+}
+
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, _ := req.Context().Value(apiKeyContextKey{}).(string)
+	if key == "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	query.Set("apikey", key)
+	req.URL.RawQuery = query.Encode()
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Mukam21/server_Golang/migrations"
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// Readiness checks what the service cannot serve requests without: the
+// database, its schema being fully migrated and the enrichment providers
+// configured as critical. Providers are judged by their circuit breakers,
+// which follow the outcome of real enrichment calls, so frequent probes do
+// not add traffic to them.
+func (s *Service) Readiness(ctx context.Context) *model.HealthReport {
+	checks := []func(ctx context.Context) model.HealthCheck{s.checkDatabase, s.checkMigrations}
+	for _, name := range s.cfg.Current().HealthCritical {
+		p := s.provider(name)
+		checks = append(checks, func(context.Context) model.HealthCheck {
+			return s.checkCircuit(p)
+		})
+	}
+	return s.runChecks(ctx, checks)
+}
+
+// Dependencies reports the reachability, latency and circuit state of every
+// enrichment provider.
+func (s *Service) Dependencies(ctx context.Context) *model.HealthReport {
+	var checks []func(ctx context.Context) model.HealthCheck
+	for _, name := range providerNames {
+		p := s.provider(name)
+		checks = append(checks, func(ctx context.Context) model.HealthCheck {
+			return s.checkProvider(ctx, p)
+		})
+	}
+	return s.runChecks(ctx, checks)
+}
+
+// runChecks runs checks concurrently within HealthCheckTimeout. The report
+// is down if a critical check is, degraded if another one is, and up
+// otherwise.
+func (s *Service) runChecks(ctx context.Context, checks []func(ctx context.Context) model.HealthCheck) *model.HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Current().HealthCheckTimeout)
+	defer cancel()
+
+	report := &model.HealthReport{Status: model.HealthUp, Checks: make([]model.HealthCheck, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, check := range report.Checks {
+		if check.Status == model.HealthUp {
+			continue
+		}
+		if check.Critical {
+			report.Status = model.HealthDown
+			break
+		}
+		report.Status = model.HealthDegraded
+	}
+	return report
+}
+
+func (s *Service) checkDatabase(ctx context.Context) model.HealthCheck {
+	check := model.HealthCheck{Name: "database", Critical: true}
+	start := time.Now()
+	err := s.repo.Ping(ctx)
+	check.LatencyMs = elapsedMs(start)
+	return withError(check, err)
+}
+
+func (s *Service) checkMigrations(ctx context.Context) model.HealthCheck {
+	check := model.HealthCheck{Name: "migrations", Critical: true}
+	start := time.Now()
+	version, dirty, err := s.repo.SchemaVersion(ctx)
+	check.LatencyMs = elapsedMs(start)
+	if err != nil {
+		return withError(check, err)
+	}
+
+	expected, err := migrations.Latest()
+	switch {
+	case err != nil:
+	case dirty:
+		err = fmt.Errorf("migration %d failed halfway", version)
+	case version < expected:
+		// A newer schema is fine: during a rolling deploy the new release
+		// migrates ahead of the replicas still running this one.
+		err = fmt.Errorf("schema is at version %d, expected at least %d", version, expected)
+	}
+	return withError(check, err)
+}
+
+// checkCircuit reports an enrichment provider down while its circuit is
+// open.
+func (s *Service) checkCircuit(p *provider) model.HealthCheck {
+	check := s.providerCheck(p)
+	if check.Circuit == circuitOpen {
+		return withError(check, errCircuitOpen)
+	}
+	return withError(check, nil)
+}
+
+// checkProvider probes an enrichment provider with a HEAD request; any
+// response short of a server error counts as reachable. A provider whose
+// circuit is open is reported down without being probed, and the outcome of
+// a probe is reused for HealthProbeInterval.
+func (s *Service) checkProvider(ctx context.Context, p *provider) model.HealthCheck {
+	check := s.providerCheck(p)
+	if check.Circuit == circuitOpen {
+		return withError(check, errCircuitOpen)
+	}
+
+	// Holding the lock through the probe makes concurrent checks wait for
+	// its outcome instead of probing too.
+	p.probe.mu.Lock()
+	defer p.probe.mu.Unlock()
+	if p.probe.at.IsZero() || time.Since(p.probe.at) >= s.cfg.Current().HealthProbeInterval {
+		start := time.Now()
+		p.probe.err = s.probe(ctx, p.url)
+		p.probe.latencyMs = elapsedMs(start)
+		p.probe.at = time.Now()
+		if ctx.Err() != nil {
+			// Cut short by the caller; let the next check probe again.
+			p.probe.at = time.Time{}
+		}
+	}
+	check.LatencyMs = p.probe.latencyMs
+	return withError(check, p.probe.err)
+}
+
+func (s *Service) providerCheck(p *provider) model.HealthCheck {
+	check := model.HealthCheck{Name: p.name, Circuit: p.breaker.State()}
+	for _, name := range s.cfg.Current().HealthCritical {
+		if name == p.name {
+			check.Critical = true
+		}
+	}
+	return check
+}
+
+// probeResult is the last probe of a provider.
+type probeResult struct {
+	mu        sync.Mutex
+	at        time.Time
+	err       error
+	latencyMs float64
+}
+
+func (s *Service) probe(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func withError(check model.HealthCheck, err error) model.HealthCheck {
+	check.Status = model.HealthUp
+	if err != nil {
+		check.Status = model.HealthDown
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func elapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
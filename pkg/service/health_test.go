@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mukam21/server_Golang/migrations"
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// healthyRepository answers the database checks of readiness, with the
+// schema offset versions away from the latest migration.
+type healthyRepository struct {
+	Repository
+	offset int
+	dirty  bool
+}
+
+func (healthyRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r healthyRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	version, err := migrations.Latest()
+	return uint(int(version) + r.offset), r.dirty, err
+}
+
+func TestReadinessSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		repo healthyRepository
+		want string
+	}{
+		{"latest", healthyRepository{}, model.HealthUp},
+		{"ahead during a rolling deploy", healthyRepository{offset: 1}, model.HealthUp},
+		{"behind", healthyRepository{offset: -1}, model.HealthDown},
+		{"dirty", healthyRepository{dirty: true}, model.HealthDown},
+		{"ahead and dirty", healthyRepository{offset: 1, dirty: true}, model.HealthDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(tt.repo)
+			s.cfg = config.NewStore(&config.Config{HealthCheckTimeout: time.Second}, nil)
+			if report := s.Readiness(context.Background()); report.Status != tt.want {
+				t.Errorf("Readiness = %+v, want %s", report, tt.want)
+			}
+		})
+	}
+}
+
+// probedService returns a service whose agify provider is critical and
+// served by a test server, and the number of requests that server got.
+func probedService(t *testing.T, probeInterval time.Duration) (*Service, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+
+	s := newTestService(healthyRepository{})
+	s.cfg = config.NewStore(&config.Config{
+		APIAgifyURL:            srv.URL,
+		HealthCritical:         []string{ProviderAgify},
+		HealthCheckTimeout:     time.Second,
+		HealthProbeInterval:    probeInterval,
+		EnrichBreakerThreshold: 1,
+		EnrichBreakerCooldown:  time.Minute,
+	}, nil)
+	s.applyConfig(s.cfg.Current())
+	return s, &requests
+}
+
+func TestReadinessFollowsBreakerWithoutProbing(t *testing.T) {
+	s, requests := probedService(t, time.Minute)
+	ctx := context.Background()
+
+	for range 3 {
+		if report := s.Readiness(ctx); report.Status != model.HealthUp {
+			t.Fatalf("Readiness = %+v, want up", report)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("readiness sent %d requests to the provider, want none", n)
+	}
+
+	breaker := s.provider(ProviderAgify).breaker
+	breaker.allow()
+	breaker.done(ctx, errors.New("connection refused"))
+	if report := s.Readiness(ctx); report.Status != model.HealthDown {
+		t.Errorf("Readiness with the circuit open = %+v, want down", report)
+	}
+}
+
+func TestDependenciesReuseProbes(t *testing.T) {
+	tests := []struct {
+		name          string
+		probeInterval time.Duration
+		wantRequests  int32
+	}{
+		{"within the interval", time.Minute, 1},
+		{"interval disabled", 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, requests := probedService(t, tt.probeInterval)
+			for range 3 {
+				report := s.Dependencies(context.Background())
+				if report.Checks[0].Status != model.HealthUp {
+					t.Fatalf("Dependencies = %+v, want the provider up", report)
+				}
+			}
+			// Only agify points at the test server.
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("provider got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// ClaimIdempotencyKey reserves key for a request with the given body hash.
+// A nil response means the caller holds the key under the returned token and
+// must run the request, then pass the token to SaveIdempotentResponse or
+// ReleaseIdempotencyKey; otherwise the response stored for the earlier
+// identical request is returned.
+func (s *Service) ClaimIdempotencyKey(ctx context.Context, key, requestHash string) (_ string, _ *model.IdempotentResponse, err error) {
+	ctx, span := startSpan(ctx, "ClaimIdempotencyKey")
+	defer endSpan(span, &err)
+
+	cfg := s.cfg.Current()
+	now := time.Now()
+	token := newClaimToken()
+	resp, err := s.repo.ClaimIdempotencyKey(ctx, key, token, requestHash,
+		now.Add(cfg.IdempotencyKeyTTL), now.Add(-cfg.IdempotencyLockTimeout))
+	if err != nil {
+		s.log.WithContext(ctx).Debugf("Idempotency key %q not claimed: %v", key, err)
+		return "", nil, err
+	}
+	if resp != nil {
+		s.log.WithContext(ctx).Infof("Replaying response for idempotency key %q", key)
+		return "", resp, nil
+	}
+	return token, nil, nil
+}
+
+func (s *Service) SaveIdempotentResponse(ctx context.Context, key, token string, resp *model.IdempotentResponse) (err error) {
+	ctx, span := startSpan(ctx, "SaveIdempotentResponse")
+	defer endSpan(span, &err)
+
+	if err := s.repo.SaveIdempotentResponse(ctx, key, token, resp); err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to save response for idempotency key %q: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Service) ReleaseIdempotencyKey(ctx context.Context, key, token string) (err error) {
+	ctx, span := startSpan(ctx, "ReleaseIdempotencyKey")
+	defer endSpan(span, &err)
+
+	if err := s.repo.ReleaseIdempotencyKey(ctx, key, token); err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to release idempotency key %q: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// RunIdempotencyCleanup deletes expired idempotency keys every PurgeInterval
+// until ctx is done.
+func (s *Service) RunIdempotencyCleanup(ctx context.Context) {
+	interval := s.cfg.Current().PurgeInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.repo.DeleteExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			s.log.WithContext(ctx).Errorf("Failed to delete expired idempotency keys: %v", err)
+		} else if deleted > 0 {
+			s.log.WithContext(ctx).Infof("Deleted %d expired idempotency keys", deleted)
+		}
+	}
+}
+
+func newClaimToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/xuri/excelize/v2"
+)
+
+// importFields are the person fields a spreadsheet column can be mapped to.
+var importFields = map[string]bool{"name": true, "surname": true, "patronymic": true}
+
+// importChunkSize is how many rows are enriched and inserted together.
+const importChunkSize = 100
+
+type ImportOptions struct {
+	// Format is "csv" or "xlsx".
+	Format string
+	// Mapping maps person fields to column headers. Fields without a mapping
+	// are read from the column named like the field.
+	Mapping map[string]string
+	// DryRun validates and deduplicates rows without creating anything.
+	DryRun bool
+	// Dedup is one of "none", "file" (skip rows repeated within the file),
+	// "existing" (skip rows matching a stored person) or "both".
+	Dedup string
+	// Async processes the rows in the background even for small files.
+	Async bool
+}
+
+type importRow struct {
+	number int
+	req    model.PersonRequest
+}
+
+// StartImport parses an uploaded spreadsheet and imports its rows through the
+// same validation and enrichment as CreatePerson. Files with more rows than
+// ImportAsyncRows, or any file when opts.Async is set, are processed in the
+// background; poll GetImport for the outcome. Jobs are stored in the
+// database, so any instance can report on them.
+func (s *Service) StartImport(ctx context.Context, r io.Reader, opts ImportOptions) (_ *model.ImportJob, err error) {
+	ctx, span := startSpan(ctx, "StartImport")
+	defer endSpan(span, &err)
+
+	records, err := readRecords(r, opts.Format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrInvalidInput, err)
+	}
+	rows, err := mapRows(records, opts.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrInvalidInput, err)
+	}
+
+	job := &model.ImportJob{
+		ID:        newJobID(),
+		Status:    model.ImportPending,
+		DryRun:    opts.DryRun,
+		TotalRows: len(rows),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to store import job: %v", err)
+		return nil, err
+	}
+	s.log.WithContext(ctx).Infof("Started import %s of %d rows", job.ID, len(rows))
+
+	if opts.Async || len(rows) > s.cfg.Current().ImportAsyncRows {
+		// The worker owns job from here on.
+		started := *job
+		ctx := context.WithoutCancel(ctx)
+		s.goWorker(func() { s.runImport(ctx, job, rows, opts) })
+		return &started, nil
+	}
+	s.runImport(ctx, job, rows, opts)
+	return job, nil
+}
+
+// GetImport returns the import job with the given id, without its row
+// errors.
+func (s *Service) GetImport(ctx context.Context, id string) (_ *model.ImportJob, err error) {
+	ctx, span := startSpan(ctx, "GetImport")
+	defer endSpan(span, &err)
+
+	job, err := s.repo.GetImportJob(ctx, id)
+	if err != nil {
+		s.log.WithContext(ctx).Debugf("Failed to get import %s: %v", id, err)
+		return nil, err
+	}
+	return job, nil
+}
+
+// ImportErrors returns the rows rejected by a finished import.
+func (s *Service) ImportErrors(ctx context.Context, id string) (_ []model.ImportRowError, err error) {
+	ctx, span := startSpan(ctx, "ImportErrors")
+	defer endSpan(span, &err)
+
+	errs, err := s.repo.ImportErrors(ctx, id)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to get errors of import %s: %v", id, err)
+		return nil, err
+	}
+	return errs, nil
+}
+
+// saveImport stores the progress of job. A failure is only logged: the
+// import itself goes on, and the final save records its outcome.
+func (s *Service) saveImport(ctx context.Context, job *model.ImportJob) {
+	if err := s.repo.UpdateImportJob(ctx, job); err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to store progress of import %s: %v", job.ID, err)
+	}
+}
+
+// RunImportCleanup deletes import jobs that have not changed for
+// ImportJobTTL every PurgeInterval until ctx is done. This also removes jobs
+// left running by an instance that stopped without finishing them.
+func (s *Service) RunImportCleanup(ctx context.Context) {
+	interval := s.cfg.Current().PurgeInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.repo.DeleteImportJobs(ctx, time.Now().Add(-s.cfg.Current().ImportJobTTL))
+		if err != nil {
+			s.log.WithContext(ctx).Errorf("Failed to delete expired import jobs: %v", err)
+		} else if deleted > 0 {
+			s.log.WithContext(ctx).Infof("Deleted %d expired import jobs", deleted)
+		}
+	}
+}
+
+func (s *Service) runImport(ctx context.Context, job *model.ImportJob, rows []importRow, opts ImportOptions) {
+	job.Status = model.ImportRunning
+	s.saveImport(ctx, job)
+
+	var rejected []model.ImportRowError
+	reject := func(row importRow, reason string) {
+		rejected = append(rejected, model.ImportRowError{Row: row.number, Reason: reason})
+	}
+
+	var accepted []importRow
+	firstSeen := make(map[string]int)
+	for _, row := range rows {
+		if err := binding.Validator.ValidateStruct(&row.req); err != nil {
+			reject(row, err.Error())
+			continue
+		}
+		if opts.Dedup == "file" || opts.Dedup == "both" {
+			key := model.NaturalKey(row.req.Name, row.req.Surname, row.req.Patronymic)
+			if first, ok := firstSeen[key]; ok {
+				reject(row, fmt.Sprintf("duplicate of row %d", first))
+				continue
+			}
+			firstSeen[key] = row.number
+		}
+		accepted = append(accepted, row)
+	}
+
+	dedupExisting := opts.Dedup == "existing" || opts.Dedup == "both"
+	imported := 0
+	for start := 0; start < len(accepted); start += importChunkSize {
+		end := start + importChunkSize
+		if end > len(accepted) {
+			end = len(accepted)
+		}
+		chunk := accepted[start:end]
+
+		reqs := make([]model.PersonRequest, len(chunk))
+		for i, row := range chunk {
+			reqs[i] = row.req
+		}
+
+		switch {
+		case opts.DryRun && dedupExisting:
+			exists, err := existingRows(ctx, s.repo, chunk)
+			if err != nil {
+				s.failImport(ctx, job, err)
+				return
+			}
+			for i, row := range chunk {
+				if exists[i] {
+					reject(row, "person already exists")
+					continue
+				}
+				imported++
+			}
+		case opts.DryRun:
+			imported += len(chunk)
+		case dedupExisting:
+			imported += s.importNew(ctx, chunk, s.newPersons(ctx, reqs), reject)
+		default:
+			_, errs := s.CreateBatch(ctx, reqs, false)
+			for i, err := range errs {
+				if err != nil {
+					reject(chunk[i], err.Error())
+					continue
+				}
+				imported++
+			}
+		}
+
+		job.Imported = imported
+		s.saveImport(ctx, job)
+	}
+
+	finished := time.Now()
+	job.Status = model.ImportCompleted
+	job.Imported = imported
+	job.Rejected = len(rejected)
+	job.Errors = rejected
+	job.FinishedAt = &finished
+	s.saveImport(ctx, job)
+	s.log.WithContext(ctx).Infof("Finished import %s: %d imported, %d rejected", job.ID, imported, len(rejected))
+}
+
+// importNew creates the persons of rows that do not exist yet and rejects
+// the others, returning how many were created. The check and the inserts
+// share a serializable transaction, so a concurrent import cannot create the
+// same persons in between; if the transaction fails, every row is rejected.
+func (s *Service) importNew(ctx context.Context, rows []importRow, persons []*model.Person, reject func(importRow, string)) int {
+	var exists []bool
+	opts := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	err := s.repo.WithTx(ctx, opts, func(tx Repository) error {
+		var err error
+		if exists, err = existingRows(ctx, tx, rows); err != nil {
+			return err
+		}
+
+		var batch []*model.Person
+		for i, person := range persons {
+			if !exists[i] {
+				batch = append(batch, person)
+			}
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		return tx.CreateBatch(ctx, batch)
+	})
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to import %d rows: %v", len(rows), err)
+		for _, row := range rows {
+			reject(row, err.Error())
+		}
+		return 0
+	}
+
+	created := 0
+	for i, row := range rows {
+		if exists[i] {
+			reject(row, "person already exists")
+			continue
+		}
+		created++
+	}
+	return created
+}
+
+// existingRows reports for each row whether a stored person has its natural
+// key.
+func existingRows(ctx context.Context, repo Repository, rows []importRow) ([]bool, error) {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		keys[i] = model.NaturalKey(row.req.Name, row.req.Surname, row.req.Patronymic)
+	}
+	existing, err := repo.ExistingKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make([]bool, len(rows))
+	for i, key := range keys {
+		exists[i] = existing[key]
+	}
+	return exists, nil
+}
+
+func (s *Service) failImport(ctx context.Context, job *model.ImportJob, err error) {
+	s.log.WithContext(ctx).Errorf("Import %s failed: %v", job.ID, err)
+	finished := time.Now()
+	job.Status = model.ImportFailed
+	job.Error = err.Error()
+	job.FinishedAt = &finished
+	s.saveImport(ctx, job)
+}
+
+// readRecords reads all rows of a CSV file or of the first sheet of an XLSX
+// workbook. CSV files may use either comma or semicolon separators.
+func readRecords(r io.Reader, format string) ([][]string, error) {
+	switch format {
+	case "csv":
+		br := bufio.NewReader(r)
+		if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+			br.Discard(3)
+		}
+		reader := csv.NewReader(br)
+		reader.FieldsPerRecord = -1
+		head, _ := br.Peek(br.Size())
+		first, _, _ := bytes.Cut(head, []byte("\n"))
+		if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+			reader.Comma = ';'
+		}
+		return reader.ReadAll()
+	case "xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		rows, err := f.Rows(sheets[0])
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var records [][]string
+		for rows.Next() {
+			cols, err := rows.Columns()
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, cols)
+		}
+		return records, rows.Error()
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// mapRows turns spreadsheet records into person requests using the header
+// row and the column mapping, skipping blank rows.
+func mapRows(records [][]string, mapping map[string]string) ([]importRow, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	for field := range mapping {
+		if !importFields[field] {
+			return nil, fmt.Errorf("unknown field %q in mapping", field)
+		}
+	}
+
+	headers := make(map[string]int)
+	for i, header := range records[0] {
+		headers[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+
+	columns := make(map[string]int)
+	for field := range importFields {
+		header := field
+		if mapped, ok := mapping[field]; ok {
+			header = mapped
+		}
+		if i, ok := headers[strings.ToLower(strings.TrimSpace(header))]; ok {
+			columns[field] = i
+		} else if field != "patronymic" {
+			return nil, fmt.Errorf("column %q for %s not found", header, field)
+		}
+	}
+
+	cell := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []importRow
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		row := importRow{
+			number: i + 2,
+			req: model.PersonRequest{
+				Name:    cell(record, "name"),
+				Surname: cell(record, "surname"),
+			},
+		}
+		if patronymic := cell(record, "patronymic"); patronymic != "" {
+			row.req.Patronymic = &patronymic
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
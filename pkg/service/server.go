@@ -1,176 +1,375 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Mukam21/server_Golang/pkg/config"
 	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/Mukam21/server_Golang/pkg/repository"
+	"github.com/Mukam21/server_Golang/pkg/requestctx"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-type Repository interface {
-	Create(person *model.Person) (int64, error)
-	GetByID(id int64) (*model.Person, error)
-	GetAll(page, limit int, filters map[string]string) ([]*model.Person, error)
-	Update(person *model.Person) error
-	Patch(id int64, patch *model.PersonPatchRequest) error
-	Delete(id int64) error
-}
+// Repository is the storage the service runs on; see repository.Repository.
+type Repository = repository.Repository
 
 type Service struct {
-	repo Repository
-	log  *logrus.Logger
-	cfg  *config.Config
+	repo   Repository
+	log    *logrus.Logger
+	cfg    *config.Store
+	client *http.Client
+
+	// providers is swapped whole when the configuration is reloaded.
+	providers atomic.Pointer[map[string]*provider]
+
+	// workers tracks background goroutines: the periodic jobs and async
+	// imports.
+	workers sync.WaitGroup
+}
+
+// NewService returns a service that follows the configuration in cfg as
+// it is reloaded.
+func NewService(repo Repository, log *logrus.Logger, cfg *config.Store) *Service {
+	s := &Service{
+		repo:   repo,
+		log:    log,
+		cfg:    cfg,
+		client: &http.Client{Transport: otelhttp.NewTransport(apiKeyTransport{base: http.DefaultTransport})},
+	}
+	s.applyConfig(cfg.Current())
+	cfg.OnReload(s.applyConfig)
+	return s
 }
 
-func NewService(repo Repository, log *logrus.Logger, cfg *config.Config) *Service {
-	return &Service{repo: repo, log: log, cfg: cfg}
+// applyConfig rebuilds the enrichment providers for cfg. Requests already
+// under way finish with the providers they started with.
+func (s *Service) applyConfig(cfg *config.Config) {
+	var current map[string]*provider
+	if p := s.providers.Load(); p != nil {
+		current = *p
+	}
+	providers := newProviders(cfg, current)
+	s.providers.Store(&providers)
 }
 
-func (s *Service) CreatePerson(req *model.PersonRequest) (*model.Person, error) {
+func (s *Service) CreatePerson(ctx context.Context, req *model.PersonRequest) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "CreatePerson")
+	defer endSpan(span, &err)
+
 	person := &model.Person{
 		Name:       req.Name,
 		Surname:    req.Surname,
 		Patronymic: req.Patronymic,
 	}
 
-	if age, err := s.getAge(person.Name); err == nil {
-		person.Age = &age
-	} else {
-		s.log.Debugf("Failed to get age for %s: %v", person.Name, err)
+	s.enrichPerson(ctx, person)
+
+	id, err := s.repo.Create(ctx, person)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to create person: %v", err)
+		return nil, err
 	}
-	if gender, err := s.getGender(person.Name); err == nil {
-		person.Gender = &gender
-	} else {
-		s.log.Debugf("Failed to get gender for %s: %v", person.Name, err)
+	person.ID = id
+
+	s.log.WithContext(ctx).Infof("Created person with ID: %d", id)
+	return person, nil
+}
+
+// CreateBatch creates persons from reqs, enriching all distinct names with
+// batched lookups. In atomic mode every person is inserted in one transaction
+// and any failure fails them all; otherwise each is inserted on its own. The
+// returned slices are aligned with reqs.
+func (s *Service) CreateBatch(ctx context.Context, reqs []model.PersonRequest, atomic bool) ([]*model.Person, []error) {
+	ctx, span := startSpan(ctx, "CreateBatch")
+	defer span.End()
+
+	persons := s.newPersons(ctx, reqs)
+
+	errs := make([]error, len(reqs))
+	if atomic {
+		if err := s.repo.CreateBatch(ctx, persons); err != nil {
+			s.log.WithContext(ctx).Errorf("Failed to create batch of %d persons: %v", len(persons), err)
+			for i := range errs {
+				errs[i] = err
+			}
+			return persons, errs
+		}
+		s.log.WithContext(ctx).Infof("Created batch of %d persons", len(persons))
+		return persons, errs
 	}
-	if nationality, err := s.getNationality(person.Name); err == nil {
-		person.Nationality = &nationality
-	} else {
-		s.log.Debugf("Failed to get nationality for %s: %v", person.Name, err)
+
+	created := 0
+	for i, person := range persons {
+		if _, err := s.repo.Create(ctx, person); err != nil {
+			s.log.WithContext(ctx).Errorf("Failed to create person %d of batch: %v", i, err)
+			errs[i] = err
+			continue
+		}
+		created++
 	}
+	s.log.WithContext(ctx).Infof("Created %d of %d persons in batch", created, len(persons))
+	return persons, errs
+}
 
-	id, err := s.repo.Create(person)
+func (s *Service) GetByID(ctx context.Context, id int64, includeDeleted bool) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "GetByID")
+	defer endSpan(span, &err)
+
+	person, err := s.repo.GetByID(ctx, id, includeDeleted)
 	if err != nil {
-		s.log.Errorf("Failed to create person: %v", err)
+		s.log.WithContext(ctx).Errorf("Failed to get person with ID %d: %v", id, err)
 		return nil, err
 	}
-	person.ID = id
-
-	s.log.Infof("Created person with ID: %d", id)
 	return person, nil
 }
 
-func (s *Service) GetByID(id int64) (*model.Person, error) {
-	person, err := s.repo.GetByID(id)
+// GetAsOf returns the person as it was at asOf. A person that was deleted at
+// that moment is only returned when includeDeleted is set.
+func (s *Service) GetAsOf(ctx context.Context, id int64, asOf time.Time, includeDeleted bool) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "GetAsOf")
+	defer endSpan(span, &err)
+
+	person, err := s.repo.GetAsOf(ctx, id, asOf)
 	if err != nil {
-		s.log.Errorf("Failed to get person with ID %d: %v", id, err)
+		s.log.WithContext(ctx).Errorf("Failed to get person with ID %d as of %s: %v", id, asOf.Format(time.RFC3339), err)
 		return nil, err
 	}
+	if person.DeletedAt != nil && !includeDeleted {
+		return nil, model.ErrNotFound
+	}
 	return person, nil
 }
 
-func (s *Service) GetAll(page, limit int, filters map[string]string) ([]*model.Person, error) {
-	persons, err := s.repo.GetAll(page, limit, filters)
+func (s *Service) GetAll(ctx context.Context, page, limit int, filters map[string]string, sort model.Sort) (_ []*model.Person, err error) {
+	ctx, span := startSpan(ctx, "GetAll")
+	defer endSpan(span, &err)
+
+	persons, err := s.repo.GetAll(ctx, page, limit, filters, sort)
 	if err != nil {
-		s.log.Errorf("Failed to get persons: %v", err)
+		s.log.WithContext(ctx).Errorf("Failed to get persons: %v", err)
 		return nil, err
 	}
-	s.log.Infof("Retrieved %d persons", len(persons))
+	s.log.WithContext(ctx).Infof("Retrieved %d persons", len(persons))
 	return persons, nil
 }
 
-func (s *Service) Update(person *model.Person) error {
-	if err := s.repo.Update(person); err != nil {
-		s.log.Errorf("Failed to update person with ID %d: %v", person.ID, err)
-		return err
+// Stats aggregates the persons matching filters, building the age histogram
+// over ageBuckets.
+func (s *Service) Stats(ctx context.Context, filters map[string]string, ageBuckets []int) (_ *model.PersonStats, err error) {
+	ctx, span := startSpan(ctx, "Stats")
+	defer endSpan(span, &err)
+
+	stats, err := s.repo.Stats(ctx, filters, ageBuckets)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to compute person stats: %v", err)
+		return nil, err
 	}
-	s.log.Infof("Updated person with ID: %d", person.ID)
-	return nil
+	return stats, nil
 }
 
-func (s *Service) Patch(id int64, patch *model.PersonPatchRequest) error {
-	if err := s.repo.Patch(id, patch); err != nil {
-		s.log.Errorf("Failed to patch person with ID %d: %v", id, err)
+// Export streams every person matching filters to fn without loading them
+// all into memory.
+func (s *Service) Export(ctx context.Context, filters map[string]string, sort model.Sort, fn func(*model.Person) error) (err error) {
+	ctx, span := startSpan(ctx, "Export")
+	defer endSpan(span, &err)
+
+	count := 0
+	err = s.repo.Export(ctx, filters, sort, func(person *model.Person) error {
+		count++
+		return fn(person)
+	})
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to export persons after %d rows: %v", count, err)
 		return err
 	}
-	s.log.Infof("Patched person with ID: %d", id)
+	s.log.WithContext(ctx).Infof("Exported %d persons", count)
 	return nil
 }
 
-func (s *Service) Delete(id int64) error {
-	if err := s.repo.Delete(id); err != nil {
-		s.log.Errorf("Failed to delete person with ID %d: %v", id, err)
+func (s *Service) Update(ctx context.Context, person *model.Person, expectedVersion int64) (err error) {
+	ctx, span := startSpan(ctx, "Update")
+	defer endSpan(span, &err)
+
+	if err := s.repo.Update(ctx, person, expectedVersion); err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to update person with ID %d: %v", person.ID, err)
 		return err
 	}
-	s.log.Infof("Deleted person with ID: %d", id)
+	s.log.WithContext(ctx).Infof("Updated person with ID: %d", person.ID)
 	return nil
 }
 
-func (s *Service) getAge(name string) (int, error) {
-	resp, err := http.Get(s.cfg.APIAgifyURL + "?name=" + name)
-	if err != nil {
-		return 0, err
+// Upsert replaces the person with the same name, surname and patronymic, or
+// creates one, enriching the fields the request leaves out. It reports
+// whether the person was created.
+func (s *Service) Upsert(ctx context.Context, person *model.Person, expectedVersion int64) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "Upsert")
+	defer endSpan(span, &err)
+
+	if strings.TrimSpace(person.Name) == "" || strings.TrimSpace(person.Surname) == "" {
+		return false, fmt.Errorf("%w: name and surname are required", model.ErrInvalidInput)
 	}
-	defer resp.Body.Close()
 
-	var result struct {
-		Age int `json:"age"`
+	// The lookup deciding on enrichment and the write share a transaction, so
+	// a person created or deleted in between makes it retry rather than
+	// store a stale decision. Enrichment only reads from the providers, so
+	// repeating it on a retry is harmless.
+	key := model.NaturalKey(person.Name, person.Surname, person.Patronymic)
+	fields := *person
+	created := false
+	err = s.repo.WithTx(ctx, nil, func(tx Repository) error {
+		*person = fields
+		existing, err := tx.GetByNaturalKey(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to look up person by natural key: %w", err)
+		}
+		if existing == nil && (person.Age == nil || person.Gender == nil || person.Nationality == nil) {
+			s.enrich(ctx, []string{person.Name})[nameKey(person.Name)].fillMissing(person)
+		}
+
+		created, err = tx.Upsert(ctx, person, expectedVersion)
+		return err
+	})
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to upsert person: %v", err)
+		return false, err
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, err
+	if created {
+		s.log.WithContext(ctx).Infof("Created person with ID: %d", person.ID)
+	} else {
+		s.log.WithContext(ctx).Infof("Updated person with ID: %d", person.ID)
 	}
-	return result.Age, nil
+	return created, nil
 }
 
-func (s *Service) getGender(name string) (string, error) {
-	resp, err := http.Get(s.cfg.APIGenderizeURL + "?name=" + name)
+func (s *Service) Patch(ctx context.Context, id, expectedVersion int64, patch *model.PersonPatchRequest) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "Patch")
+	defer endSpan(span, &err)
+
+	person, err := s.repo.Patch(ctx, id, expectedVersion, patch)
 	if err != nil {
-		return "", err
+		s.log.WithContext(ctx).Errorf("Failed to patch person with ID %d: %v", id, err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	s.log.WithContext(ctx).Infof("Patched person with ID: %d", id)
+	return person, nil
+}
 
-	var result struct {
-		Gender string `json:"gender"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+func (s *Service) Delete(ctx context.Context, id, expectedVersion int64) (err error) {
+	ctx, span := startSpan(ctx, "Delete")
+	defer endSpan(span, &err)
+
+	if err := s.repo.Delete(ctx, id, expectedVersion); err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to delete person with ID %d: %v", id, err)
+		return err
 	}
-	if result.Gender != "male" && result.Gender != "female" {
-		return "other", nil
+	s.log.WithContext(ctx).Infof("Deleted person with ID: %d", id)
+	return nil
+}
+
+func (s *Service) Restore(ctx context.Context, id, expectedVersion int64) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "Restore")
+	defer endSpan(span, &err)
+
+	person, err := s.repo.Restore(ctx, id, expectedVersion)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to restore person with ID %d: %v", id, err)
+		return nil, err
 	}
-	return result.Gender, nil
+	s.log.WithContext(ctx).Infof("Restored person with ID: %d", id)
+	return person, nil
 }
 
-func (s *Service) getNationality(name string) (string, error) {
-	resp, err := http.Get(s.cfg.APINationalizeURL + "?name=" + name)
+func (s *Service) Revert(ctx context.Context, id, version, expectedVersion int64) (_ *model.Person, err error) {
+	ctx, span := startSpan(ctx, "Revert")
+	defer endSpan(span, &err)
+
+	person, err := s.repo.Revert(ctx, id, version, expectedVersion)
 	if err != nil {
-		return "", err
+		s.log.WithContext(ctx).Errorf("Failed to revert person with ID %d to version %d: %v", id, version, err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	s.log.WithContext(ctx).Infof("Reverted person with ID %d to version %d", id, version)
+	return person, nil
+}
 
-	var result struct {
-		Country []struct {
-			CountryID   string  `json:"country_id"`
-			Probability float64 `json:"probability"`
-		} `json:"country"`
+func (s *Service) History(ctx context.Context, id int64, page, limit int) (_ []*model.AuditEntry, err error) {
+	ctx, span := startSpan(ctx, "History")
+	defer endSpan(span, &err)
+
+	entries, err := s.repo.History(ctx, id, page, limit)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to get history of person with ID %d: %v", id, err)
+		return nil, err
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	return entries, nil
+}
+
+// Start runs the periodic background jobs until ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	s.goWorker(func() { s.RunPurger(ctx) })
+	s.goWorker(func() { s.RunIdempotencyCleanup(ctx) })
+	s.goWorker(func() { s.RunImportCleanup(ctx) })
+}
+
+// Wait blocks until the background jobs have stopped and async imports have
+// finished, or until ctx is done.
+func (s *Service) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	if len(result.Country) == 0 {
-		return "", nil
+func (s *Service) goWorker(fn func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn()
+	}()
+}
+
+// RunPurger permanently removes persons soft-deleted longer than the
+// configured retention period, checking every PurgeInterval until ctx is
+// done. It returns immediately when retention is disabled.
+func (s *Service) RunPurger(ctx context.Context) {
+	cfg := s.cfg.Current()
+	if cfg.SoftDeleteRetentionDays <= 0 || cfg.PurgeInterval <= 0 {
+		s.log.WithContext(ctx).Info("Purging of soft-deleted persons is disabled")
+		return
 	}
 
-	maxProb := 0.0
-	var maxCountry string
-	for _, country := range result.Country {
-		if country.Probability > maxProb {
-			maxProb = country.Probability
-			maxCountry = country.CountryID
+	ctx = requestctx.WithActor(ctx, "system:purge")
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		retention := time.Duration(cfg.SoftDeleteRetentionDays) * 24 * time.Hour
+		purged, err := s.repo.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			s.log.WithContext(ctx).Errorf("Failed to purge deleted persons: %v", err)
+		} else if purged > 0 {
+			s.log.WithContext(ctx).Infof("Purged %d persons deleted more than %d days ago", purged, cfg.SoftDeleteRetentionDays)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 	}
-	return maxCountry, nil
 }
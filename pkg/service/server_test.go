@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+
+	"github.com/Mukam21/server_Golang/pkg/config"
+	"github.com/Mukam21/server_Golang/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// txRepository records which repository the calls of a transaction go to.
+// Methods it does not override panic through the nil embedded interface.
+type txRepository struct {
+	Repository
+	inTx     bool
+	attempts int
+	calls    []string
+}
+
+func (r *txRepository) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx Repository) error) error {
+	bound := &txRepository{inTx: true}
+	for {
+		r.attempts++
+		err := fn(bound)
+		r.calls = append(r.calls, bound.calls...)
+		bound.calls = nil
+		// Fail the first attempt like a serialization failure would.
+		if r.attempts == 1 {
+			continue
+		}
+		return err
+	}
+}
+
+func (r *txRepository) GetByNaturalKey(ctx context.Context, key string) (*model.Person, error) {
+	r.calls = append(r.calls, txCall("GetByNaturalKey", r.inTx))
+	return nil, nil
+}
+
+func (r *txRepository) Upsert(ctx context.Context, person *model.Person, expectedVersion int64) (bool, error) {
+	r.calls = append(r.calls, txCall("Upsert", r.inTx))
+	if person.ID != 0 {
+		return false, model.ErrVersionMismatch
+	}
+	person.ID = 7
+	return true, nil
+}
+
+func txCall(method string, inTx bool) string {
+	if inTx {
+		return method + " in tx"
+	}
+	return method
+}
+
+func newTestService(repo Repository) *Service {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewService(repo, log, config.NewStore(&config.Config{}, nil))
+}
+
+func TestUpsertRunsInTransaction(t *testing.T) {
+	repo := &txRepository{}
+	s := newTestService(repo)
+	age, gender, nationality := 30, "male", "RU"
+	person := &model.Person{Name: "Ivan", Surname: "Petrov", Age: &age, Gender: &gender, Nationality: &nationality}
+
+	created, err := s.Upsert(context.Background(), person, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || person.ID != 7 {
+		t.Errorf("created = %v, id = %d; want a created person 7", created, person.ID)
+	}
+
+	want := []string{"GetByNaturalKey in tx", "Upsert in tx", "GetByNaturalKey in tx", "Upsert in tx"}
+	if len(repo.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", repo.calls, want)
+	}
+	for i := range want {
+		if repo.calls[i] != want[i] {
+			t.Errorf("calls = %v, want %v", repo.calls, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mukam21/server_Golang/pkg/model"
+)
+
+// TimeSeries counts the persons matching filters created in [from, to) per
+// interval in loc, optionally split by groupBy. Every interval overlapping
+// the range gets a point, zero if nobody was created in it.
+func (s *Service) TimeSeries(ctx context.Context, filters map[string]string, interval string, loc *time.Location, groupBy string, from, to time.Time) (_ *model.TimeSeries, err error) {
+	ctx, span := startSpan(ctx, "TimeSeries")
+	defer endSpan(span, &err)
+
+	counts, err := s.repo.CountCreated(ctx, filters, interval, loc.String(), groupBy, from, to)
+	if err != nil {
+		s.log.WithContext(ctx).Errorf("Failed to count created persons: %v", err)
+		return nil, err
+	}
+
+	var starts []time.Time
+	for start := model.TruncateTime(from.In(loc), interval); start.Before(to); start = model.NextInterval(start, interval) {
+		starts = append(starts, start)
+	}
+
+	type bucketCounts struct {
+		value  *string
+		counts map[int64]int64
+	}
+	groups := make(map[string]*bucketCounts)
+	var order []*bucketCounts
+	for _, count := range counts {
+		key := "\x00"
+		if count.Value != nil {
+			key = *count.Value
+		}
+		group := groups[key]
+		if group == nil {
+			group = &bucketCounts{value: count.Value, counts: make(map[int64]int64)}
+			groups[key] = group
+			order = append(order, group)
+		}
+		// The database returns bucket starts as wall-clock times in loc.
+		start := time.Date(count.Start.Year(), count.Start.Month(), count.Start.Day(),
+			count.Start.Hour(), count.Start.Minute(), count.Start.Second(), 0, loc)
+		group.counts[start.Unix()] += count.Count
+	}
+	if groupBy == "" && len(order) == 0 {
+		order = append(order, &bucketCounts{})
+	}
+
+	series := &model.TimeSeries{
+		Interval: interval,
+		TimeZone: loc.String(),
+		GroupBy:  groupBy,
+		From:     from,
+		To:       to,
+		Groups:   make([]model.TimeSeriesGroup, 0, len(order)),
+	}
+	for _, group := range order {
+		points := make([]model.TimeSeriesPoint, len(starts))
+		for i, start := range starts {
+			points[i] = model.TimeSeriesPoint{Start: start, Count: group.counts[start.Unix()]}
+		}
+		series.Groups = append(series.Groups, model.TimeSeriesGroup{Value: group.value, Points: points})
+	}
+
+	return series, nil
+}
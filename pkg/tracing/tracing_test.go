@@ -0,0 +1,49 @@
+package tracing
+
+import "testing"
+
+func TestSanitizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "parameters are kept",
+			query: "SELECT id FROM persons WHERE id = $1",
+			want:  "SELECT id FROM persons WHERE id = $1",
+		},
+		{
+			name:  "whitespace is collapsed",
+			query: "\n        SELECT id\n        FROM persons\tWHERE id = $1",
+			want:  "SELECT id FROM persons WHERE id = $1",
+		},
+		{
+			name:  "string literals are hidden",
+			query: "SELECT id FROM persons WHERE name = 'Ivan' AND surname = 'Petrov'",
+			want:  "SELECT id FROM persons WHERE name = ? AND surname = ?",
+		},
+		{
+			name:  "escaped quotes stay inside the literal",
+			query: "SELECT 'O''Brien', 'x'",
+			want:  "SELECT ?, ?",
+		},
+		{
+			name:  "empty literal",
+			query: "SELECT coalesce(error, '') FROM import_jobs",
+			want:  "SELECT coalesce(error, ?) FROM import_jobs",
+		},
+		{
+			name:  "whitespace inside a literal goes with it",
+			query: "SELECT 'two  words'",
+			want:  "SELECT ?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeQuery(tt.query); got != tt.want {
+				t.Errorf("SanitizeQuery = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}